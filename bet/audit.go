@@ -0,0 +1,60 @@
+package bet
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/mtyurt/slackbet"
+	"github.com/mtyurt/slackbet/repo"
+)
+
+// auditLogLimit is the number of latest entries shown by GetAuditLog, exports are not limited.
+const auditLogLimit = 20
+
+func (service *BetService) audit(entry repo.AuditEntry) error {
+	entry.Timestamp = time.Now()
+	return service.Repo.AddAuditEntry(entry)
+}
+
+// GetAuditLog lists the latest audit entries of the bet, entries of all bets and other commands if betID is -1.
+// Guesses of the open bet are hidden unless user may see them.
+func (service *BetService) GetAuditLog(user string, betID int) (*slackbet.AuditLog, error) {
+	entries, err := service.auditEntries(user, betID)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) > auditLogLimit {
+		entries = entries[len(entries)-auditLogLimit:]
+	}
+	return &slackbet.AuditLog{Entries: entries}, nil
+}
+
+// ExportAuditLog returns all audit entries of the bet, entries of all bets and other commands if betID is -1.
+// Guesses of the open bet are hidden unless user may see them.
+func (service *BetService) ExportAuditLog(user string, betID int) (*slackbet.AuditExport, error) {
+	entries, err := service.auditEntries(user, betID)
+	if err != nil {
+		return nil, err
+	}
+	return &slackbet.AuditExport{Entries: entries}, nil
+}
+
+func (service *BetService) auditEntries(user string, betID int) ([]repo.AuditEntry, error) {
+	if !service.HasPermission(user, "audit") {
+		return nil, errors.New("You are not authorized to read the audit log.")
+	}
+	entries, err := service.Repo.GetAuditEntries(betID)
+	if err != nil {
+		return nil, err
+	}
+	return service.revealAuditEntries(user, entries)
+}
+
+// scoreString formats a score for the audit log, -1 means there is no score.
+func scoreString(score int) string {
+	if score == -1 {
+		return ""
+	}
+	return strconv.Itoa(score)
+}
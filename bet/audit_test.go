@@ -0,0 +1,78 @@
+package bet
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAuditLog(t *testing.T) {
+	service := mockService()
+	client, err := openRedis()
+	defer client.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Cmd("FLUSHALL")
+
+	resp, err := service.GetAuditLog("sezgin", -1)
+	if err != nil || text(service, resp) != "audit log is empty." {
+		t.Fatal("audit log should be empty", err, resp)
+	}
+	if _, err = service.GetAuditLog("omer", -1); err == nil || err.Error() != "You are not authorized to read the audit log." {
+		t.Fatal("players should not read the audit log", err)
+	}
+	service.StartNewBet("sezgin")
+	service.SaveBet("omer", 100, "")
+	service.SaveBet("omer", 120, "")
+	_, err = service.SaveBetFor("omer", "tarik", 90)
+	if err == nil || err.Error() != "You are not authorized to save a bet for someone else." {
+		t.Fatal("save for should fail", err)
+	}
+	service.SaveBetFor("sezgin", "tarik", 90)
+	service.EndBet("sezgin")
+	service.SaveWinner("sezgin", 1, 110)
+	service.SaveWinner("sezgin", 1, 115)
+
+	resp, err = service.GetAuditLog("sezgin", 1)
+	if err != nil {
+		t.Fatal("audit log failed", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(text(service, resp), "\n"), "\n")
+	expected := []string{
+		"#1\tsezgin\tstart\tbet 1\t -> ",
+		"#2\tomer\tsave\tbet 1\tomer\t -> 100",
+		"#3\tomer\tsave\tbet 1\tomer\t100 -> 120",
+		"#4\tsezgin\tsavefor\tbet 1\ttarik\t -> 90",
+		"#5\tsezgin\tend\tbet 1\topen -> closed",
+		"#6\tsezgin\tsavewinner\tbet 1\t -> 110",
+		"#7\tsezgin\tsavewinner\tbet 1\t110 -> 115",
+	}
+	if len(lines) != len(expected) {
+		t.Fatal("audit log is wrong", resp)
+	}
+	for i, line := range lines {
+		fields := strings.Split(line, "\t")
+		// drop the timestamp
+		line = strings.Join(append(fields[:1], fields[2:]...), "\t")
+		if !strings.HasPrefix(line, expected[i]) {
+			t.Fatal("audit entry is wrong, expected", expected[i], "but was", line)
+		}
+	}
+
+	resp, err = service.GetAuditLog("sezgin", 2)
+	if err != nil || text(service, resp) != "audit log is empty." {
+		t.Fatal("audit log of bet 2 should be empty", err, resp)
+	}
+
+	export, err := service.ExportAuditLog("sezgin", 1)
+	if err != nil || len(export.Entries) != 7 {
+		t.Fatal("audit export failed", err, export)
+	}
+	lines = strings.Split(strings.TrimSuffix(text(service, export), "\n"), "\n")
+	if len(lines) != 8 || lines[0] != "id,timestamp,actor,action,betId,target,oldValue,newValue,reverts" {
+		t.Fatal("audit export is wrong", lines)
+	}
+	if !strings.HasPrefix(lines[4], "4,") || !strings.HasSuffix(lines[4], ",sezgin,savefor,1,tarik,,90,0") {
+		t.Fatal("audit export entry is wrong", lines[4])
+	}
+}
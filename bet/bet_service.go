@@ -1,22 +1,38 @@
 package bet
 
 import (
+	"context"
 	"errors"
-	"fmt"
+	"log/slog"
 	"net/http"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/mtyurt/slackbet"
+	"github.com/mtyurt/slackbet/chart"
+	"github.com/mtyurt/slackbet/format"
 	"github.com/mtyurt/slackbet/repo"
 )
 
+const (
+	defaultListCount = 5
+	maxListCount     = 50
+)
+
 type BetService struct {
 	Repo         repo.Repo
 	Conf         *slackbet.Conf
 	SlackService slackbet.SlackService
+	// Uploader uploads charts when a bet ends if Conf.UploadCharts is set, they are not uploaded if it is nil.
+	Uploader slackbet.FileUploader
+	// Logger is slog.Default() if it is nil.
+	Logger *slog.Logger
+	// Callbacks tracks callbacks that are being sent, they are not tracked if it is nil.
+	// It is a pointer since copies of the service share it.
+	Callbacks *sync.WaitGroup
 }
 type ByBet []repo.BetDetail
 
@@ -24,42 +40,84 @@ func (a ByBet) Len() int           { return len(a) }
 func (a ByBet) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 func (a ByBet) Less(i, j int) bool { return a[i].Number < a[j].Number }
 
-func (service *BetService) SaveWinner(betID int, winner int) (string, error) {
-	exists, err := service.Repo.BetIDExists(betID)
-	if err != nil || !exists {
-		return "", errors.New("No such bet exists.")
+func (service *BetService) SaveWinner(user string, betID int, winner int) (*slackbet.Confirmation, error) {
+	if !service.HasPermission(user, "savewinner") {
+		return nil, errors.New("You are not authorized to save a winner.")
+	}
+	summary, err := service.getExistingBetSummary(betID)
+	if err != nil {
+		return nil, err
 	}
+	if summary.Sealed && summary.Status == "open" {
+		return nil, errors.New("Bet " + strconv.Itoa(betID) + " is sealed, end it before saving the winner score.")
+	}
+	oldWinner := summary.WinnerNumber
 
 	err = service.Repo.SetBetWinner(betID, winner)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return "winner " + strconv.Itoa(winner) + "for bet " + strconv.Itoa(betID) + " is saved successfully", err
+	err = service.audit(repo.AuditEntry{Actor: user, Action: "savewinner", BetID: betID, OldValue: scoreString(oldWinner), NewValue: strconv.Itoa(winner)})
+	if err != nil {
+		return nil, err
+	}
+	if summary.Sealed && oldWinner == -1 {
+		// the end of a sealed bet only asks for reveals, its guesses are posted when reveals are over.
+		service.async(func() { service.sendBetEndedCallback(betID) })
+	}
+	return &slackbet.Confirmation{Action: "savewinner", BetID: betID, Value: strconv.Itoa(winner)}, nil
 }
 
-func (service *BetService) ListAbsentUsers() (string, error) {
+// ListAbsentUsers lists the channel members who have not placed a bet in the open bet, and posts them to the channel.
+// It fails if Conf.OpenBetReveal hides the participants, since they are the channel members who are not listed.
+func (service *BetService) ListAbsentUsers(user string) (*slackbet.AbsentList, error) {
+	if !service.HasPermission(user, "listabsent") {
+		return nil, errors.New("You are not authorized to list absent users.")
+	}
+	if service.Reveal("", "open") < slackbet.RevealParticipants {
+		return nil, errors.New("Participants of the open bet are hidden.")
+	}
 	openBetID, err := service.Repo.GetIDOfOpenBet()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	if openBetID == -1 {
-		return "there is no active bet.", err
+		return &slackbet.AbsentList{BetID: -1}, nil
 	}
 	betDetails, err := service.Repo.GetBetDetails(openBetID)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	go service.doListAbsentUsers(betDetails)
-	return "ok", nil
+	channelMembers, err := service.SlackService.GetChannelMembers(service.Conf.ChannelID)
+	if err != nil {
+		service.logger().Error("channel members cannot be read", "channelId", service.Conf.ChannelID, "err", err)
+		return nil, errors.New("channel members cannot be read from Slack.")
+	}
+	absent := &slackbet.AbsentList{BetID: openBetID, Users: absentUsers(channelMembers, betDetails)}
+	service.sendCallback(format.New(service.Conf).Text(absent))
+	return absent, nil
 }
 
-func (service *BetService) doListAbsentUsers(betDetails []repo.BetDetail) {
-	channelMembers, err := service.SlackService.GetChannelMembers(service.Conf.ChannelID)
+// CountOpenBetParticipants returns the number of users who placed a bet in the open bet, 0 if there is no open bet.
+// It returns -1 if the count of the open bet is hidden from everyone.
+func (service *BetService) CountOpenBetParticipants() (int, error) {
+	if service.Reveal("", "open") < slackbet.RevealCount {
+		return -1, nil
+	}
+	openBetID, err := service.Repo.GetIDOfOpenBet()
+	if err != nil || openBetID == -1 {
+		return 0, err
+	}
+	details, err := service.Repo.GetBetDetails(openBetID)
 	if err != nil {
-		fmt.Println(err)
-		return
+		return 0, err
 	}
+	return len(details), nil
+}
 
+// absentUsers returns the channel members who have no bet in betDetails.
+func absentUsers(channelMembers []string, betDetails []repo.BetDetail) []string {
+	channelMembers = append([]string(nil), channelMembers...)
 	for i := 0; i < len(betDetails); i++ {
 		detail := betDetails[i]
 		for j, member := range channelMembers {
@@ -69,37 +127,35 @@ func (service *BetService) doListAbsentUsers(betDetails []repo.BetDetail) {
 			}
 		}
 	}
-
-	service.SlackService.SendCallback("Users who have not placed a bet yet: "+strings.Join(channelMembers, ", "), service.Conf.Channel)
+	return channelMembers
 }
 
-func (service *BetService) CalculateWhoWins(reference int) (string, error) {
-	betID, err := service.Repo.GetLastBetID()
+// CalculateWhoWins lists who would win the latest bet if the winner score was reference.
+// The report of a bet whose guesses are hidden in the channel is only marked open.
+func (service *BetService) CalculateWhoWins(reference int) (*slackbet.WinnerReport, error) {
+	summaries, err := service.getBetSummaryList(1)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	if betID == -1 {
-		return "No bet exists", nil
+	if len(summaries) == 0 {
+		return &slackbet.WinnerReport{BetID: -1, Reference: reference}, nil
 	}
-	openBetID, err := service.Repo.GetIDOfOpenBet()
-	if err != nil {
-		return "", err
-	}
-	if openBetID == betID {
-		return "you cannot query who wins for an active bet! I'm telling mom", nil
+	betID := summaries[0].ID
+	report := &slackbet.WinnerReport{BetID: betID, Reference: reference}
+	if service.Reveal("", summaries[0].Status) != slackbet.RevealAll {
+		report.Open = true
+		return report, nil
 	}
 	details, err := service.Repo.GetBetDetails(betID)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	totalUser := len(details)
-	details = service.getWinners(details, reference)
-	summary := "bet " + strconv.Itoa(betID) + ", " + strconv.Itoa(totalUser) + " people joined, hypothetical " + strconv.Itoa(totalUser/2) + " winners for score " + strconv.Itoa(reference) + ": "
-	responseStr := summary + "\n"
-	for _, detail := range details {
-		responseStr += "\t" + detail.User + "\t" + strconv.Itoa(detail.Number) + "\n"
+	details, _ = qualifiedDetails(details)
+	report.Participants = len(details)
+	for _, detail := range service.getWinners(details, reference) {
+		report.Winners = append(report.Winners, slackbet.Entry{User: detail.User, Number: detail.Number, ExtraInfo: detail.ExtraInfo, Winner: true})
 	}
-	return responseStr, nil
+	return report, nil
 }
 func (service *BetService) getWinners(details []repo.BetDetail, score int) []repo.BetDetail {
 	userBetMap := make(map[string]int)
@@ -122,145 +178,197 @@ func (service *BetService) getWinners(details []repo.BetDetail, score int) []rep
 	}
 	return winners
 }
-func (service *BetService) GetLastEndedBetInfo() (string, error) {
-	betID, err := service.Repo.GetLastBetID()
-	if err != nil {
-		return "", err
-	}
-	if betID == -1 {
-		return "No bet exists", nil
-	}
-	openBetID, err := service.Repo.GetIDOfOpenBet()
+
+// GetLastEndedBetInfo returns the latest closed bet, nil if there is no bet.
+func (service *BetService) GetLastEndedBetInfo() (*slackbet.BetInfo, error) {
+	summaries, err := service.getBetSummaryList(2)
 	if err != nil {
-		return "", nil
+		return nil, err
 	}
-	if betID == openBetID {
-		betID = betID - 1
+	if len(summaries) == 0 {
+		return nil, nil
 	}
-	exists, err := service.Repo.BetIDExists(betID)
-	if err != nil || !exists {
-		return "", errors.New("No such bet exists.")
+	summary := summaries[len(summaries)-1]
+	if summary.Status == "open" {
+		if len(summaries) == 1 {
+			return nil, errors.New("No such bet exists.")
+		}
+		summary = summaries[0]
 	}
-	summary, err := service.Repo.GetBetSummary(betID)
+	bet, err := service.Repo.GetBetWithDetails(summary.ID)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	if bet == nil {
+		return nil, errors.New("No such bet exists.")
 	}
-	return service.generateBetDetails(betID, summary.String())
+	return service.betInfo(bet), nil
 }
 
-func (service *BetService) GetBetInfo(id int) (string, error) {
+// GetBetInfo returns the bet with id, the latest bet if id is -1. Deleted bets don't exist, nil is returned if there is no bet.
+func (service *BetService) GetBetInfo(id int) (*slackbet.BetInfo, error) {
 	var err error
 	betID := id
 	if betID == -1 {
-		betID, err = service.Repo.GetLastBetID()
+		summaries, err := service.getBetSummaryList(1)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
-		if betID == -1 {
-			return "No bet exists", nil
+		if len(summaries) == 0 {
+			return nil, nil
 		}
+		betID = summaries[0].ID
 	}
-	exists, err := service.Repo.BetIDExists(betID)
-	if err != nil || !exists {
-		return "", errors.New("No such bet exists.")
-	}
-	summary, err := service.Repo.GetBetSummary(betID)
+	bet, err := service.Repo.GetBetWithDetails(betID)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	openBetID, err := service.Repo.GetIDOfOpenBet()
+	if bet == nil || bet.Visibility == repo.VisibilityDeleted {
+		return nil, errors.New("No such bet exists.")
+	}
+	return service.betInfo(bet), nil
+}
+
+// GetBetInfoForPeriod returns the latest visible bet of a month, see slackbet.ParsePeriod for accepted periods.
+func (service *BetService) GetBetInfoForPeriod(text string) (*slackbet.BetInfo, error) {
+	period, err := slackbet.ParsePeriod(text, time.Now().In(service.Conf.Location()))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	if openBetID == betID {
-		return summary.String(), nil
+	ids, err := service.Repo.GetBetIDsForPeriod(period.Format(repo.PeriodFormat))
+	if err != nil {
+		return nil, err
 	}
-	return service.generateBetDetails(betID, summary.String())
-}
-func (service *BetService) GetBetInfoForMonth(monthIndex int) (string, error) {
-	summaries, err := service.getBetSummaryList(12)
+	summaries, err := service.Repo.GetBetSummaries(ids)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	reverse(summaries)
-	var summary *repo.BetSummary
-	for i := 0; i < len(summaries); i++ {
-		s := summaries[i]
-		date := s.EndDate
-		if s.EndDate == "" {
-			date = s.StartDate
-		}
-		month, err := strconv.Atoi(date[3:5])
-		if err != nil {
-			return "", err
-		}
-		if month == monthIndex+1 {
-			summary = &s
+	betID := -1
+	for _, summary := range summaries {
+		if summary.Visibility == "" {
+			betID = summary.ID
 			break
 		}
 	}
-	if summary == nil {
-		return "", errors.New("bet for month " + slackbet.Months[monthIndex] + " not found.")
+	notFound := errors.New("bet for " + strings.ToLower(period.Format("January 2006")) + " not found.")
+	if betID == -1 {
+		return nil, notFound
 	}
-	openBetID, err := service.Repo.GetIDOfOpenBet()
+	bet, err := service.Repo.GetBetWithDetails(betID)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	if openBetID == summary.ID {
-		return summary.String(), nil
+	if bet == nil {
+		return nil, notFound
 	}
-	return service.generateBetDetails(summary.ID, summary.String())
+	return service.betInfo(bet), nil
 }
-func (service *BetService) generateBetDetails(betID int, summary string) (string, error) {
-	details, err := service.Repo.GetBetDetails(betID)
-	if err != nil {
-		return "", err
-	}
+
+// betInfo returns the bet with its details sorted by number and winners marked, as much as everyone may see of it.
+// Sealed guesses that are not revealed or don't match their commitment are disqualified.
+// Details of an open bet are hidden, and so is their count if Conf.OpenBetReveal is nothing.
+func (service *BetService) betInfo(bet *repo.BetWithDetails) *slackbet.BetInfo {
+	info := summaryInfo(&bet.BetSummary)
+	status := bet.Status
+	if bet.IsOpen {
+		status = "open"
+	}
+	reveal := service.Reveal("", status)
+	info.EntryCount = -1
+	if reveal >= slackbet.RevealCount {
+		info.EntryCount = len(bet.Details)
+	}
+	if reveal != slackbet.RevealAll {
+		return info
+	}
+	details, disqualified := qualifiedDetails(bet.Details)
+	info.Disqualified = disqualified
 	sort.Sort(ByBet(details))
-	winnerScore, err := service.Repo.GetWinnerScore(betID)
-	winners := make(map[string]int)
-	if winnerScore != -1 {
+	winners := make(map[string]bool)
+	if bet.WinnerNumber != -1 {
 		winnerUsers := make([]repo.BetDetail, len(details))
 		copy(winnerUsers, details)
-		winnerUsers = service.getWinners(winnerUsers, winnerScore)
-		for _, detail := range winnerUsers {
-			winners[detail.User] = detail.Number
+		for _, detail := range service.getWinners(winnerUsers, bet.WinnerNumber) {
+			winners[detail.User] = true
 		}
 	}
-	responseStr := summary + "\n\n"
-	for i, detail := range details {
-		userSummary := strconv.Itoa(i+1) + ".\t" + detail.User + "\t" + strconv.Itoa(detail.Number)
-		if detail.ExtraInfo != "" {
-			userSummary += "\t" + detail.ExtraInfo
-		}
-		if _, ok := winners[detail.User]; ok {
-			userSummary = "*" + userSummary + " (WINNER!)*"
-		}
-		responseStr += userSummary + "\n"
+	info.Entries = []slackbet.Entry{}
+	for _, detail := range details {
+		info.Entries = append(info.Entries, slackbet.Entry{User: detail.User, Number: detail.Number, ExtraInfo: detail.ExtraInfo, Winner: winners[detail.User]})
 	}
-	return responseStr, nil
+	return info
 }
 
-func (service *BetService) EndBet(user string) (string, error) {
-	if !service.IsAuthorizedUser(user) {
-		return "", errors.New("You are not authorized to end a bet.")
+// summaryInfo returns the bet of the summary without its entries.
+func summaryInfo(summary *repo.BetSummary) *slackbet.BetInfo {
+	return &slackbet.BetInfo{ID: summary.ID, Status: summary.Status, StartDate: summary.StartDate, EndDate: summary.EndDate,
+		WinnerScore: summary.WinnerNumber, Visibility: summary.Visibility, Sealed: summary.Sealed}
+}
+
+func (service *BetService) EndBet(user string) (*slackbet.Confirmation, error) {
+	if !service.HasPermission(user, "end") {
+		return nil, errors.New("You are not authorized to end a bet.")
 	}
 	openBetID, err := service.Repo.GetIDOfOpenBet()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	if openBetID == -1 {
-		return "", errors.New("There is no active bet right now.")
+		return nil, errors.New("There is no active bet right now.")
 	}
-	date := time.Now().Format(slackbet.TimeFormat)
-	err = service.Repo.SetBetAsEnded(openBetID, date)
+	err = service.Repo.SetBetAsEnded(openBetID, time.Now().In(service.Conf.Location()))
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	err = service.audit(repo.AuditEntry{Actor: user, Action: "end", BetID: openBetID, OldValue: "open", NewValue: "closed"})
+	if err != nil {
+		return nil, err
+	}
+	service.async(func() { service.sendBetEndedCallback(openBetID) })
+	return &slackbet.Confirmation{Action: "end", BetID: openBetID}, nil
+}
+
+// HasPermission reports whether the role of user is enough to run the command.
+func (service *BetService) HasPermission(user string, command string) bool {
+	return service.GetUserRole(user) >= service.requiredRole(command)
+}
+
+// GetUserRole returns the role of user, conf admins are owners and they can't be changed by commands.
+// Users without a saved role are players.
+func (service *BetService) GetUserRole(user string) slackbet.Role {
+	if service.isConfOwner(user) {
+		return slackbet.RoleOwner
+	}
+	roles, err := service.Repo.GetUserRoles()
+	if err != nil {
+		service.logger().Error("roles cannot be read", "err", err)
+		return slackbet.RolePlayer
+	}
+	role, err := slackbet.ParseRole(roles[strings.ToLower(user)])
+	if err != nil {
+		return slackbet.RolePlayer
 	}
-	go service.sendBetEndedCallback(openBetID)
-	return "ended bet[" + strconv.Itoa(openBetID) + "] successfully", nil
+	return role
 }
-func (service *BetService) IsAuthorizedUser(user string) bool {
+
+// requiredRole returns the minimum role for the command.
+// An unknown role name in conf locks the command for everyone but owners.
+func (service *BetService) requiredRole(command string) slackbet.Role {
+	name, ok := service.Conf.Permissions[command]
+	if !ok {
+		name, ok = slackbet.DefaultPermissions[command]
+	}
+	if !ok {
+		return slackbet.RolePlayer
+	}
+	role, err := slackbet.ParseRole(name)
+	if err != nil {
+		return slackbet.RoleOwner
+	}
+	return role
+}
+
+func (service *BetService) isConfOwner(user string) bool {
 	for _, n := range service.Conf.Admins {
 		if strings.EqualFold(n, user) {
 			return true
@@ -269,120 +377,363 @@ func (service *BetService) IsAuthorizedUser(user string) bool {
 	return false
 }
 
+// SetUserRole changes the role of user. Actor can't grant a role higher than their own,
+// and can't change the role of someone who is above them.
+func (service *BetService) SetUserRole(actor string, user string, roleName string) (*slackbet.Confirmation, error) {
+	role, err := slackbet.ParseRole(roleName)
+	if err != nil {
+		return nil, err
+	}
+	actorRole := service.GetUserRole(actor)
+	if actorRole < service.requiredRole("role") {
+		return nil, errors.New("You are not authorized to manage roles.")
+	}
+	if service.isConfOwner(user) {
+		return nil, errors.New(user + " is an owner in conf, their role can only be changed from conf.")
+	}
+	oldRole := service.GetUserRole(user)
+	if role > actorRole || oldRole > actorRole {
+		return nil, errors.New("You cannot change roles above your own.")
+	}
+	if oldRole == role {
+		return nil, errors.New(user + " is already " + role.String() + ".")
+	}
+	if role == slackbet.RolePlayer {
+		err = service.Repo.RemoveUserRole(user)
+	} else {
+		err = service.Repo.SetUserRole(user, role.String())
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = service.audit(repo.AuditEntry{Actor: actor, Action: "role", Target: user, OldValue: oldRole.String(), NewValue: role.String()})
+	if err != nil {
+		return nil, err
+	}
+	return &slackbet.Confirmation{Action: "role", User: user, Value: role.String()}, nil
+}
+
+// ListRoles lists the users of every role above player, conf admins are owners.
+func (service *BetService) ListRoles() (*slackbet.RoleList, error) {
+	roles, err := service.Repo.GetUserRoles()
+	if err != nil {
+		return nil, err
+	}
+	list := &slackbet.RoleList{Users: make(map[slackbet.Role][]string)}
+	list.Users[slackbet.RoleOwner] = append(list.Users[slackbet.RoleOwner], service.Conf.Admins...)
+	for user, name := range roles {
+		role, err := slackbet.ParseRole(name)
+		if err != nil || service.isConfOwner(user) || role == slackbet.RolePlayer {
+			continue
+		}
+		list.Users[role] = append(list.Users[role], user)
+	}
+	for _, users := range list.Users {
+		sort.Strings(users)
+	}
+	return list, nil
+}
+
 func (service *BetService) sendBetEndedCallback(betID int) {
 	betInfo, err := service.GetBetInfo(betID)
 	if err != nil {
-		fmt.Println(err.Error())
+		service.logger().Error("ended bet cannot be read", "betId", betID, "err", err)
+		return
+	}
+	formatter := format.New(service.Conf)
+	if betInfo.Sealed && betInfo.WinnerScore == -1 {
+		service.SlackService.SendCallback("bet["+strconv.Itoa(betID)+"] has ended, reveal your sealed guess with /bet reveal <number> <salt>"+
+			" before the winner score is saved. Guesses that are not revealed are disqualified.", service.Conf.Channel)
 		return
 	}
-	service.SlackService.SendCallback(betInfo, service.Conf.Channel)
+	text := formatter.Text(betInfo)
+	if stats := guessStats(betInfo); stats != nil {
+		text = strings.TrimSuffix(text, "\n") + "\n\n" + formatter.Text(stats)
+	}
+	service.SlackService.SendCallback(text, service.Conf.Channel)
+	if service.Uploader != nil && service.Conf.UploadCharts {
+		title := "Guesses of bet #" + strconv.Itoa(betID)
+		err = service.Uploader.UploadFile("bet-"+strconv.Itoa(betID)+".svg", title, chart.Histogram(betInfo), service.Conf.ChannelID)
+		if err != nil {
+			service.logger().Error("chart cannot be uploaded", "betId", betID, "err", err)
+		}
+	}
+}
+
+// SaveBet saves the guess of user in the open bet. In a sealed bet only a commitment of the guess is saved,
+// its salt is returned to the user to reveal it after the bet ends.
+func (service *BetService) SaveBet(user string, number int, extraInfo string) (*slackbet.Confirmation, error) {
+	return service.saveBet(user, "save", repo.BetDetail{User: user, Number: number, ExtraInfo: extraInfo})
 }
 
-func (service *BetService) SaveBet(user string, number int, extraInfo string) (string, error) {
+// SaveBetFor saves a bet in the name of user, actor is recorded in the audit log.
+func (service *BetService) SaveBetFor(actor string, user string, number int) (*slackbet.Confirmation, error) {
+	if !service.HasPermission(actor, "savefor") {
+		return nil, errors.New("You are not authorized to save a bet for someone else.")
+	}
+	return service.saveBet(actor, "savefor", repo.BetDetail{User: user, Number: number})
+}
+
+func (service *BetService) saveBet(actor string, action string, detail repo.BetDetail) (*slackbet.Confirmation, error) {
 	openBetID, err := service.Repo.GetIDOfOpenBet()
 	if openBetID == -1 {
-		return "", errors.New("There is no active bet right now.")
+		return nil, errors.New("There is no active bet right now.")
+	}
+	summary, err := service.Repo.GetBetSummary(openBetID)
+	if err != nil {
+		return nil, err
+	}
+	// number is the guess that the actor typed, it is unknown if only a commitment is given.
+	number := ""
+	if detail.Commitment == "" {
+		number = strconv.Itoa(detail.Number)
+	}
+	salt := ""
+	switch {
+	case summary.Sealed && action == "savefor":
+		return nil, errors.New("Guesses of a sealed bet can only be saved by their users.")
+	case summary.Sealed && detail.Commitment == "":
+		salt, err = newSalt()
+		if err != nil {
+			return nil, err
+		}
+		action, detail.Commitment, detail.Number = "seal", Commit(detail.Number, salt), 0
+	case !summary.Sealed && detail.Commitment != "":
+		return nil, errors.New("Bet " + strconv.Itoa(openBetID) + " is not sealed, save your guess with /bet save <number>.")
 	}
 
 	details, err := service.Repo.GetBetDetails(openBetID)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	oldValue, oldExtraInfo := "", ""
+	if i := findBet(details, detail.User); i != -1 {
+		oldValue, oldExtraInfo = detailValue(details[i]), details[i].ExtraInfo
 	}
-	details = appendBetToList(details, user, number, extraInfo)
+	details = appendBetToList(details, detail)
 	err = service.Repo.SetBetDetail(openBetID, details)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	err = service.audit(repo.AuditEntry{Actor: actor, Action: action, BetID: openBetID, Target: detail.User, OldValue: oldValue, NewValue: detailValue(detail),
+		ExtraInfo: oldExtraInfo})
+	if err != nil {
+		return nil, err
+	}
+	if text := service.placedBetText(detail.User, len(details)); text != "" {
+		service.sendCallback(text)
 	}
-	go service.SlackService.SendCallback(user+" has placed a bet. Have you?", service.Conf.Channel)
-	return "saved successfully", nil
+	confirmation := &slackbet.Confirmation{Action: action, BetID: openBetID, User: detail.User, Salt: salt}
+	if actor == detail.User || service.Reveal(actor, "open") == slackbet.RevealAll {
+		confirmation.Value = number
+	}
+	return confirmation, nil
 }
 
-func appendBetToList(list []repo.BetDetail, user string, number int, extraInfo string) []repo.BetDetail {
-	found := false
+// appendBetToList returns a copy of list with the bet of detail.User replaced by detail, or added if there is none.
+func appendBetToList(list []repo.BetDetail, detail repo.BetDetail) []repo.BetDetail {
 	newList := make([]repo.BetDetail, len(list))
-
-	for i, elem := range list {
-		newElem := repo.BetDetail{User: elem.User, Number: elem.Number, ExtraInfo: elem.ExtraInfo}
-		if elem.User == user {
-			newElem.Number = number
-			newElem.ExtraInfo = extraInfo
-			found = true
-		}
-		newList[i] = newElem
+	copy(newList, list)
+	if i := findBet(newList, detail.User); i != -1 {
+		newList[i] = detail
+		return newList
 	}
-	if !found {
-		newList = append(newList, repo.BetDetail{User: user, Number: number, ExtraInfo: extraInfo})
-	}
-	return newList
+	return append(newList, detail)
 }
 
-func (service *BetService) StartNewBet(user string) (string, error) {
-	if !service.IsAuthorizedUser(user) {
-		return "", errors.New("You are not authorized to start a bet.")
+func (service *BetService) StartNewBet(user string) (*slackbet.Confirmation, error) {
+	if !service.HasPermission(user, "start") {
+		return nil, errors.New("You are not authorized to start a bet.")
 	}
 	openBetID, err := service.Repo.GetIDOfOpenBet()
 	if err != nil {
-		return "openbetid", err
+		return nil, err
 	}
 	if openBetID != -1 {
-		return "", errors.New("There is a bet in progress, please finish it first.")
+		return nil, errors.New("There is a bet in progress, please finish it first.")
 	}
 	lastBetID, err := service.Repo.GetLastBetID()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	if lastBetID == -1 {
 		lastBetID = 0
 	}
 	newID := lastBetID + 1
-	err = service.Repo.AddNewBet(newID, time.Now().Format(slackbet.TimeFormat))
+	startDate := time.Now().In(service.Conf.Location())
+	err = service.Repo.AddNewBet(newID, startDate, service.Conf.SealedBets)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	err = service.audit(repo.AuditEntry{Actor: user, Action: "start", BetID: newID, NewValue: startDate.Format(time.RFC3339)})
+	if err != nil {
+		return nil, err
 	}
 
-	go service.SlackService.SendCallback("A new bet has started!", service.Conf.Channel)
-	return "started bet[" + strconv.Itoa(newID) + "] successfully", nil
+	if service.Conf.SealedBets {
+		service.sendCallback("A new sealed bet has started! Guesses are kept as commitments until you reveal them.")
+	} else {
+		service.sendCallback("A new bet has started!")
+	}
+	return &slackbet.Confirmation{Action: "start", BetID: newID}, nil
 }
 
-func (service *BetService) ListBets() (string, error) {
-	summaries, err := service.getBetSummaryList(5)
+// ListBets lists a page of visible bets that match the query in ascending order.
+// A query without count lists defaultListCount bets, an empty page is returned if nothing matches.
+func (service *BetService) ListBets(query slackbet.ListQuery) (*slackbet.BetList, error) {
+	if query.Count < 1 {
+		query.Count = defaultListCount
+	}
+	if query.Count > maxListCount {
+		return nil, errors.New("at most " + strconv.Itoa(maxListCount) + " bets can be listed at once.")
+	}
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	skip := (query.Page - 1) * query.Count
+	matches := 0
+	list := &slackbet.BetList{Bets: []slackbet.BetInfo{}, Page: query.Page}
+	var summaries []repo.BetSummary
+	err := service.walkBets(query.Count, func(summary *repo.BetSummary) bool {
+		if !service.matchesQuery(summary, query) {
+			return true
+		}
+		if matches++; matches <= skip {
+			return true
+		}
+		if len(summaries) == query.Count {
+			list.More = true
+			return false
+		}
+		summaries = append(summaries, *summary)
+		return true
+	})
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	response := ""
-	for _, summary := range summaries {
-		response += summary.String() + "\n"
+	if len(summaries) == 0 && matches > 0 {
+		pageCount := (matches + query.Count - 1) / query.Count
+		return nil, errors.New("page " + strconv.Itoa(query.Page) + " is out of range, there are " + strconv.Itoa(pageCount) + " pages.")
+	}
+	for i := len(summaries) - 1; i >= 0; i-- {
+		list.Bets = append(list.Bets, *summaryInfo(&summaries[i]))
+	}
+	return list, nil
+}
+
+func (service *BetService) matchesQuery(summary *repo.BetSummary, query slackbet.ListQuery) bool {
+	if query.Status != "" && summary.Status != query.Status {
+		return false
+	}
+	if query.HasWinner && summary.WinnerNumber == -1 {
+		return false
 	}
-	return response, nil
+	if query.Year != 0 {
+		date := summary.EndDate
+		if date.IsZero() {
+			date = summary.StartDate
+		}
+		if date.In(service.Conf.Location()).Year() != query.Year {
+			return false
+		}
+	}
+	return true
 }
 
+// getBetSummaryList returns summaries of the last count bets in ascending order.
+// Purged, deleted and archived bets are skipped.
 func (service *BetService) getBetSummaryList(count int) ([]repo.BetSummary, error) {
-	lastID, err := service.Repo.GetLastBetID()
+	var list []repo.BetSummary
+	err := service.walkBets(count, func(summary *repo.BetSummary) bool {
+		list = append(list, *summary)
+		return len(list) < count
+	})
 	if err != nil {
 		return nil, err
 	}
-	if lastID < 1 {
-		return nil, nil
-	}
-	length := count
-	base := lastID - length + 1
-	if base < 1 {
-		length = lastID
-		base = 1
-	}
+	reverse(list)
+	return list, nil
+}
 
-	fmt.Println("list with base:", base, "length:", length, "lastID:", lastID)
-	list := make([]repo.BetSummary, length)
-	for i := 0; i < length; i++ {
-		summary, err := service.Repo.GetBetSummary(base + i)
+// walkBets calls visit with the summaries of the visible bets from the newest to the oldest until it returns false.
+// Summaries are read chunk bets at a time, so that older bets are not read if visit stops early.
+func (service *BetService) walkBets(chunk int, visit func(*repo.BetSummary) bool) error {
+	lastID, err := service.Repo.GetLastBetID()
+	if err != nil {
+		return err
+	}
+	service.logger().Debug("walking bets", "lastId", lastID, "chunk", chunk)
+	for to := lastID; to >= 1; to -= chunk {
+		summaries, err := service.Repo.GetBetSummaryRange(to-chunk+1, to)
 		if err != nil {
-			return nil, err
-		} else {
-			list[i] = *summary
+			return err
+		}
+		for i := len(summaries) - 1; i >= 0; i-- {
+			if summaries[i].Status != "" && summaries[i].Visibility == "" && !visit(&summaries[i]) {
+				return nil
+			}
 		}
 	}
-	return list, nil
+	return nil
+}
+
+// async runs f in the background, tracked by service.Callbacks.
+func (service *BetService) async(f func()) {
+	if service.Callbacks == nil {
+		go f()
+		return
+	}
+	service.Callbacks.Add(1)
+	go func() {
+		defer service.Callbacks.Done()
+		f()
+	}()
+}
+
+// sendCallback posts text to the bet channel in the background.
+func (service *BetService) sendCallback(text string) {
+	service.async(func() { service.SlackService.SendCallback(text, service.Conf.Channel) })
+}
+
+// WaitCallbacks waits until the callbacks being sent are done.
+// returns ctx.Err() if ctx is done before that.
+func (service *BetService) WaitCallbacks(ctx context.Context) error {
+	if service.Callbacks == nil {
+		return nil
+	}
+	done := make(chan struct{})
+	go func() {
+		service.Callbacks.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
+
+func (service *BetService) logger() *slog.Logger {
+	if service.Logger == nil {
+		return slog.Default()
+	}
+	return service.Logger
+}
+
+func (service *BetService) getExistingBetSummary(betID int) (*repo.BetSummary, error) {
+	exists, err := service.Repo.BetIDExists(betID)
+	if err != nil || !exists {
+		return nil, errors.New("No such bet exists.")
+	}
+	return service.Repo.GetBetSummary(betID)
+}
+
+// formatSummary formats the summary with dates in the team's timezone and date format.
+func (service *BetService) formatSummary(summary *repo.BetSummary) string {
+	return summary.Format(service.Conf.DateLayout(), service.Conf.Location())
+}
+
 func reverse(ss []repo.BetSummary) {
 	last := len(ss) - 1
 	for i := 0; i < len(ss)/2; i++ {
@@ -1,11 +1,16 @@
 package bet
 
 import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/mediocregopher/radix.v2/redis"
 	"github.com/mtyurt/slackbet"
+	"github.com/mtyurt/slackbet/format"
 	"github.com/mtyurt/slackbet/repo"
 )
 
@@ -27,7 +32,7 @@ func TestStartBet(t *testing.T) {
 	}
 
 	startResp, err = service.StartNewBet("sezgin")
-	if err != nil || startResp != "started bet[1] successfully" {
+	if err != nil || text(service, startResp) != "started bet[1] successfully" {
 		t.Fatal("start failed", err, startResp)
 	}
 	startResp, err = service.StartNewBet("sezgin")
@@ -41,8 +46,9 @@ func TestStartBet(t *testing.T) {
 	if err != nil {
 		t.Fatal("bet entry doesn't exist")
 	}
-	if betMap["startDate"] != time.Now().Format(slackbet.TimeFormat) {
-		t.Fatal("start date is wrong", betMap["startDate"])
+	startDate, err := time.Parse(time.RFC3339, betMap["startDate"])
+	if err != nil || time.Since(startDate) > time.Minute {
+		t.Fatal("start date is wrong", betMap["startDate"], err)
 	}
 	if betMap["details"] != "[]" {
 		t.Fatal("details is wrong", betMap["details"])
@@ -67,7 +73,7 @@ func TestSaveBet(t *testing.T) {
 	client.Cmd("FLUSHALL")
 
 	saveResp, err := service.SaveBet("user1", 100, "")
-	if err == nil || err.Error() != "There is no active bet right now." || saveResp != "" {
+	if err == nil || err.Error() != "There is no active bet right now." || saveResp != nil {
 		t.Fatal("save bet should fail, returned error: ", err)
 	}
 
@@ -135,7 +141,7 @@ func TestSaveBetForAnotherUser(t *testing.T) {
 	client.Cmd("FLUSHALL")
 
 	saveResp, err := service.SaveBet("user1", 100, "")
-	if err == nil || err.Error() != "There is no active bet right now." || saveResp != "" {
+	if err == nil || err.Error() != "There is no active bet right now." || saveResp != nil {
 		t.Fatal("save bet should fail, returned error: ", err)
 	}
 
@@ -166,8 +172,8 @@ func TestListBets(t *testing.T) {
 	}
 	client.Cmd("FLUSHALL")
 
-	listResp, err := service.ListBets()
-	if err != nil || listResp != "" {
+	listResp, err := service.ListBets(slackbet.ListQuery{})
+	if err != nil || text(service, listResp) != "" {
 		t.Fatal("list failed", err, listResp)
 	}
 
@@ -177,8 +183,8 @@ func TestListBets(t *testing.T) {
 	client.Cmd("HMSET", 3, "startDate", "01-02-2016", "status", "open", "details", jsonStr)
 	client.Cmd("SET", "LastID", 3)
 	expectedStr := "1\tstart: 01-02-2016\tend: 02-02-2016\n2\tstart: 01-02-2016\tend: 02-02-2016\n3\tstart: 01-02-2016\t(still open)\n"
-	listResp, err = service.ListBets()
-	if err != nil || listResp != expectedStr {
+	listResp, err = service.ListBets(slackbet.ListQuery{})
+	if err != nil || text(service, listResp) != expectedStr {
 		t.Fatal("list failed", err, "expected\n", expectedStr, "but was\n", listResp)
 	}
 
@@ -189,12 +195,57 @@ func TestListBets(t *testing.T) {
 	client.Cmd("HMSET", 5, "startDate", "01-02-2016", "endDate", "02-02-2016", "status", "closed")
 	client.Cmd("HMSET", 6, "startDate", "01-02-2016", "endDate", "02-02-2016", "status", "closed")
 	client.Cmd("HMSET", 7, "startDate", "01-02-2016", "endDate", "02-02-2016", "status", "closed")
-	expectedStr = "3\tstart: 01-02-2016\tend: 02-02-2016\n4\tstart: 01-02-2016\tend: 02-02-2016\n5\tstart: 01-02-2016\tend: 02-02-2016\n6\tstart: 01-02-2016\tend: 02-02-2016\n7\tstart: 01-02-2016\tend: 02-02-2016\n"
-	listResp, err = service.ListBets()
-	if err != nil || listResp != expectedStr {
+	expectedStr = "3\tstart: 01-02-2016\tend: 02-02-2016\n4\tstart: 01-02-2016\tend: 02-02-2016\n5\tstart: 01-02-2016\tend: 02-02-2016\n6\tstart: 01-02-2016\tend: 02-02-2016\n7\tstart: 01-02-2016\tend: 02-02-2016\npage 1, older bets are on page 2\n"
+	listResp, err = service.ListBets(slackbet.ListQuery{})
+	if err != nil || text(service, listResp) != expectedStr {
 		t.Fatal("list failed", err, "expected\n", expectedStr, "but was\n", listResp)
 	}
 }
+func TestListBetsWithQuery(t *testing.T) {
+	service := mockService()
+	client, err := openRedis()
+	defer client.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Cmd("FLUSHALL")
+
+	for id := 1; id <= 11; id++ {
+		date := time.Date(2015+id/6, time.Month(id%12+1), 1, 0, 0, 0, 0, time.UTC).Format(time.RFC3339)
+		client.Cmd("HMSET", id, "startDate", date, "endDate", date, "status", "closed")
+		if id%3 == 0 {
+			client.Cmd("HSET", id, "winner", 100)
+		}
+	}
+	client.Cmd("HMSET", 12, "startDate", "2017-01-01T00:00:00Z", "status", "open")
+	client.Cmd("HSET", 5, "visibility", "archived")
+	client.Cmd("SET", "LastID", 12)
+
+	listResp, err := service.ListBets(slackbet.ListQuery{Count: 3, Page: 2})
+	if err != nil || text(service, listResp) != "7\tstart: 01-08-2016\tend: 01-08-2016\n8\tstart: 01-09-2016\tend: 01-09-2016\n9\tstart: 01-10-2016\tend: 01-10-2016\twinner score: 100\npage 2, older bets are on page 3\n" {
+		t.Fatal("list failed", err, listResp)
+	}
+	listResp, err = service.ListBets(slackbet.ListQuery{Count: 3, Page: 4})
+	if err != nil || text(service, listResp) != "1\tstart: 01-02-2015\tend: 01-02-2015\n2\tstart: 01-03-2015\tend: 01-03-2015\npage 4, the last page\n" {
+		t.Fatal("list failed", err, listResp)
+	}
+	_, err = service.ListBets(slackbet.ListQuery{Count: 3, Page: 5})
+	if err == nil || err.Error() != "page 5 is out of range, there are 4 pages." {
+		t.Fatal("list should fail", err)
+	}
+	listResp, err = service.ListBets(slackbet.ListQuery{Status: "open"})
+	if err != nil || text(service, listResp) != "12\tstart: 01-01-2017\t(still open)\n" {
+		t.Fatal("list failed", err, listResp)
+	}
+	listResp, err = service.ListBets(slackbet.ListQuery{HasWinner: true, Year: 2016})
+	if err != nil || text(service, listResp) != "6\tstart: 01-07-2016\tend: 01-07-2016\twinner score: 100\n9\tstart: 01-10-2016\tend: 01-10-2016\twinner score: 100\n" {
+		t.Fatal("list failed", err, listResp)
+	}
+	_, err = service.ListBets(slackbet.ListQuery{Count: 51})
+	if err == nil || err.Error() != "at most 50 bets can be listed at once." {
+		t.Fatal("list should fail", err)
+	}
+}
 func TestEndBet(t *testing.T) {
 	service := mockService()
 	client, err := openRedis()
@@ -213,13 +264,62 @@ func TestEndBet(t *testing.T) {
 	if err == nil || err.Error() != "You are not authorized to end a bet." {
 		t.Fatal("end bet should fail", err)
 	}
-	client.Cmd("HMSET", 1, "startDate", "01-02-2016", "endDate", "02-02-2016", "status", "open")
+	mockService := &MockService{}
+	service.SlackService = mockService
+	client.Cmd("HMSET", 1, "startDate", "2016-02-01T00:00:00Z", "status", "open", "winner", 100,
+		"details", `[{"User":"user1","Number":75},{"User":"user2","Number":125},{"User":"user3","Number":90}]`)
 	client.Cmd("SET", "OpenBet", 1)
+	client.Cmd("SET", "LastID", 1)
 	endResp, err = service.EndBet("sezgin")
-	if err != nil && endResp != "ended bet[1] successfully" {
+	if err != nil || text(service, endResp) != "ended bet[1] successfully" {
 		t.Fatal("end bet failed", err, endResp)
 	}
+	service.WaitCallbacks(context.Background())
+	expected := "\n\n3 guesses, min 75, max 125, mean 96.7, median 90, std dev 20.9\nthe median guess was 10 off the winner score 100"
+	if callback := mockService.lastCallback(); !strings.HasPrefix(callback, "1\tstart: 01-02-2016") || !strings.HasSuffix(callback, "\t125"+expected) {
+		t.Fatal("announcement should have stats", callback)
+	}
+}
+func TestEndBetUploadsChart(t *testing.T) {
+	service := mockService()
+	uploader := &mockUploader{}
+	service.Uploader = uploader
+	service.Conf.ChannelID = "C123"
+	client, err := openRedis()
+	defer client.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Cmd("FLUSHALL")
+	service.StartNewBet("sezgin")
+	service.SaveBet("omer", 100, "")
+	service.EndBet("sezgin")
+	service.WaitCallbacks(context.Background())
+	if len(uploader.uploads) != 0 {
+		t.Fatal("charts should not be uploaded unless uploadCharts is set", uploader.uploads)
+	}
+	service.Conf.UploadCharts = true
+	service.StartNewBet("sezgin")
+	service.SaveBet("omer", 100, "")
+	service.EndBet("sezgin")
+	service.WaitCallbacks(context.Background())
+	if len(uploader.uploads) != 1 || uploader.uploads[0] != "bet-2.svg Guesses of bet #2 C123" {
+		t.Fatal("chart is not uploaded", uploader.uploads)
+	}
+}
+
+type mockUploader struct {
+	mu      sync.Mutex
+	uploads []string
+}
+
+func (uploader *mockUploader) UploadFile(filename string, title string, content []byte, channelID string) error {
+	uploader.mu.Lock()
+	defer uploader.mu.Unlock()
+	uploader.uploads = append(uploader.uploads, filename+" "+title+" "+channelID)
+	return nil
 }
+
 func TestGetBet(t *testing.T) {
 	service := mockService()
 	client, err := openRedis()
@@ -229,7 +329,7 @@ func TestGetBet(t *testing.T) {
 	}
 	client.Cmd("FLUSHALL")
 	getResp, err := service.GetBetInfo(-1)
-	if err != nil && getResp != "No bet exists" {
+	if err != nil || getResp != nil {
 		t.Fatal("get bet failed", err, getResp)
 	}
 	jsonStr := "[{\"User\":\"user1\",\"Number\":100,\"ExtraInfo\":\"\"},{\"User\":\"user2\",\"Number\":75,\"ExtraInfo\":\"\"}]"
@@ -239,19 +339,20 @@ func TestGetBet(t *testing.T) {
 	client.Cmd("SET", "LastID", 3)
 
 	getResp, err = service.GetBetInfo(2)
-	if err != nil || getResp != "2\tstart: 01-02-2016\tend: 02-02-2016\n\n1.\tuser2\t75\n2.\tuser1\t100\n" {
+	if err != nil || text(service, getResp) != "2\tstart: 01-02-2016\tend: 02-02-2016\n\n1.\tuser2\t75\n2.\tuser1\t100\n" {
 		t.Fatal("get bet failed", err, "response:", getResp)
 	}
-	getResp, err = service.GetBetInfoForMonth(1)
-	if err != nil || getResp != "2\tstart: 01-02-2016\tend: 02-02-2016\n\n1.\tuser2\t75\n2.\tuser1\t100\n" {
+	indexPeriods(t, service)
+	getResp, err = service.GetBetInfoForPeriod("february 2016")
+	if err != nil || text(service, getResp) != "2\tstart: 01-02-2016\tend: 02-02-2016\n\n1.\tuser2\t75\n2.\tuser1\t100\n" {
 		t.Fatal("get bet failed", err, "response:", getResp)
 	}
 	getResp, err = service.GetBetInfo(3)
-	if err != nil || getResp != "3\tstart: 01-03-2016\t(still open)" {
+	if err != nil || text(service, getResp) != "3\tstart: 01-03-2016\t(still open)" {
 		t.Fatal("get bet failed", err, getResp)
 	}
-	getResp, err = service.GetBetInfoForMonth(2)
-	if err != nil || getResp != "3\tstart: 01-03-2016\t(still open)" {
+	getResp, err = service.GetBetInfoForPeriod("2016-03")
+	if err != nil || text(service, getResp) != "3\tstart: 01-03-2016\t(still open)" {
 		t.Fatal("get bet failed", err, getResp)
 	}
 	getResp, err = service.GetBetInfo(4)
@@ -261,10 +362,77 @@ func TestGetBet(t *testing.T) {
 
 	client.Cmd("SET", "LastID", 2)
 	getResp, err = service.GetBetInfo(-1)
-	if err != nil || getResp != "2\tstart: 01-02-2016\tend: 02-02-2016\n\n1.\tuser2\t75\n2.\tuser1\t100\n" {
+	if err != nil || text(service, getResp) != "2\tstart: 01-02-2016\tend: 02-02-2016\n\n1.\tuser2\t75\n2.\tuser1\t100\n" {
 		t.Fatal("get bet failed", err, getResp)
 	}
 }
+func TestGetBetInTimezone(t *testing.T) {
+	service := mockService()
+	service.Conf.Timezone = "America/New_York"
+	service.Conf.DateFormat = "2006-01-02"
+	service.Repo = &repo.RedisRepo{Url: "localhost:37564", Location: service.Conf.Location()}
+	client, err := openRedis()
+	defer client.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Cmd("FLUSHALL")
+	client.Cmd("HMSET", 1, "startDate", "2016-01-01T10:00:00Z", "endDate", "2016-02-01T02:00:00Z", "status", "closed", "details", "[]")
+	client.Cmd("SET", "LastID", 1)
+	indexPeriods(t, service)
+
+	getResp, err := service.GetBetInfoForPeriod("jan 2016")
+	if err != nil || text(service, getResp) != "1\tstart: 2016-01-01\tend: 2016-01-31\n\n" {
+		t.Fatal("get bet failed", err, getResp)
+	}
+}
+func TestGetBetForRelativePeriod(t *testing.T) {
+	service := mockService()
+	client, err := openRedis()
+	defer client.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Cmd("FLUSHALL")
+	now := time.Now().UTC()
+	lastMonth := time.Date(now.Year(), now.Month()-1, 15, 0, 0, 0, 0, time.UTC)
+	client.Cmd("HMSET", 1, "startDate", lastMonth.AddDate(-1, 0, 0).Format(time.RFC3339), "endDate", lastMonth.AddDate(-1, 0, 0).Format(time.RFC3339), "status", "closed", "details", "[]")
+	client.Cmd("HMSET", 2, "startDate", lastMonth.Format(time.RFC3339), "endDate", lastMonth.Format(time.RFC3339), "status", "closed", "details", "[]")
+	client.Cmd("HMSET", 3, "startDate", now.Format(time.RFC3339), "status", "open", "details", "[]")
+	client.Cmd("SET", "LastID", 3)
+	indexPeriods(t, service)
+
+	getResp, err := service.GetBetInfoForPeriod("last month")
+	if err != nil || !strings.HasPrefix(text(service, getResp), "2\t") {
+		t.Fatal("get bet failed", err, getResp)
+	}
+	getResp, err = service.GetBetInfoForPeriod(slackbet.Months[lastMonth.Month()-1])
+	if err != nil || !strings.HasPrefix(text(service, getResp), "2\t") {
+		t.Fatal("get bet failed", err, getResp)
+	}
+	getResp, err = service.GetBetInfoForPeriod(lastMonth.AddDate(-1, 0, 0).Format("2006-01"))
+	if err != nil || !strings.HasPrefix(text(service, getResp), "1\t") {
+		t.Fatal("get bet failed", err, getResp)
+	}
+	getResp, err = service.GetBetInfoForPeriod("this month")
+	if err != nil || !strings.HasPrefix(text(service, getResp), "3\t") || !strings.Contains(text(service, getResp), "still open") {
+		t.Fatal("get bet failed", err, getResp)
+	}
+	service.EndBet("sezgin")
+	service.ReopenBet("sezgin", 3)
+	getResp, err = service.GetBetInfoForPeriod("this month")
+	if err != nil || !strings.HasPrefix(text(service, getResp), "3\t") {
+		t.Fatal("get bet failed after reopen", err, getResp)
+	}
+	_, err = service.GetBetInfoForPeriod("march 1999")
+	if err == nil || err.Error() != "bet for march 1999 not found." {
+		t.Fatal("get bet should fail", err)
+	}
+	_, err = service.GetBetInfoForPeriod("marchh")
+	if err == nil || err.Error() != "marchh is not a valid month." {
+		t.Fatal("get bet should fail", err)
+	}
+}
 func TestWhoWins(t *testing.T) {
 	service := mockService()
 	client, err := openRedis()
@@ -273,26 +441,27 @@ func TestWhoWins(t *testing.T) {
 		t.Fatal(err)
 	}
 	client.Cmd("FLUSHALL")
-	getResp, err := service.GetBetInfo(-1)
-	if err != nil && getResp != "No bet exists" {
-		t.Fatal("who wins failed", err, getResp)
+	report, err := service.CalculateWhoWins(100)
+	if err != nil || report.BetID != -1 || text(service, report) != "No bet exists" {
+		t.Fatal("who wins failed", err, report)
 	}
 	jsonStr := "[{\"User\":\"user1\",\"Number\":100},{\"User\":\"user2\",\"Number\":75},{\"User\":\"user3\",\"Number\":175},{\"User\":\"user4\",\"Number\":275},{\"User\":\"user5\",\"Number\":120}]"
 	client.Cmd("HMSET", 2, "startDate", "01-02-2016", "endDate", "02-02-2016", "status", "closed", "details", jsonStr)
 	client.Cmd("HMSET", 3, "startDate", "01-02-2016", "status", "open", "details", "[]")
 	client.Cmd("SET", "OpenBet", 3)
+	client.Cmd("SET", "LastID", 3)
 
-	getResp, err = service.CalculateWhoWins(100)
-	if err != nil && getResp != "you cannot query who wins for an active bet! I'm telling mom" {
-		t.Fatal("who wins failed", err, getResp)
+	report, err = service.CalculateWhoWins(100)
+	if err != nil || !report.Open || text(service, report) != "you cannot query who wins for an active bet! I'm telling mom" {
+		t.Fatal("who wins failed", err, report)
 	}
 	client.Cmd("DEL", 3)
 	client.Cmd("SET", "OpenBet", -1)
 	client.Cmd("SET", "LastID", 2)
 
-	getResp, err = service.CalculateWhoWins(130)
-	if err != nil || getResp != "bet 2, 5 people joined, hypothetical 2 winners for score 130: \n\tuser5\t120\n\tuser1\t100\n" {
-		t.Fatal("who wins failed", err, getResp)
+	report, err = service.CalculateWhoWins(130)
+	if err != nil || text(service, report) != "bet 2, 5 people joined, hypothetical 2 winners for score 130: \n\tuser5\t120\n\tuser1\t100\n" {
+		t.Fatal("who wins failed", err, report)
 	}
 }
 func TestListAbsentUsers(t *testing.T) {
@@ -310,10 +479,18 @@ func TestListAbsentUsers(t *testing.T) {
 	client.Cmd("SET", "OpenBet", 2)
 
 	mockService.channelMembers = []string{"user1", "user2", "user3", "user4", "user5", "user6", "user7"}
-	resp, err := service.ListAbsentUsers()
-	if err != nil || resp != "ok" {
+	_, err = service.ListAbsentUsers("user1")
+	if err == nil || err.Error() != "You are not authorized to list absent users." {
+		t.Fatal("list absent users should fail", err)
+	}
+	resp, err := service.ListAbsentUsers("sezgin")
+	if err != nil || resp.BetID != 2 || !reflect.DeepEqual(resp.Users, []string{"user6", "user7"}) {
 		t.Fatal("list absent users failed, err:", err, "response: ", resp)
 	}
+	service.WaitCallbacks(context.Background())
+	if callback := mockService.lastCallback(); callback != "Users who have not placed a bet yet: user6, user7" {
+		t.Fatal("callback is wrong", callback)
+	}
 }
 func TestSaveWinner(t *testing.T) {
 	service := mockService()
@@ -326,19 +503,88 @@ func TestSaveWinner(t *testing.T) {
 	jsonStr := "[{\"User\":\"user1\",\"Number\":100},{\"User\":\"user2\",\"Number\":75},{\"User\":\"user3\",\"Number\":500},{\"User\":\"user4\",\"Number\":200}]"
 	client.Cmd("HMSET", 2, "startDate", "01-02-2016", "endDate", "02-02-2016", "status", "closed", "details", jsonStr)
 
-	getResp, err := service.SaveWinner(2, 250)
-	if err != nil {
-		t.Fatal("save winner failed with error", err)
+	_, err = service.SaveWinner("tarik", 2, 250)
+	if err == nil || err.Error() != "You are not authorized to save a winner." {
+		t.Fatal("save winner should fail", err)
 	}
-	getResp, err = service.GetBetInfo(2)
-	if err != nil || getResp != "2\tstart: 01-02-2016\tend: 02-02-2016\twinner score: 250\n\n1.\tuser2\t75\n*2.\tuser1\t100 (WINNER!)*\n*3.\tuser4\t200 (WINNER!)*\n4.\tuser3\t500\n" {
+	saveResp, err := service.SaveWinner("sezgin", 2, 250)
+	if err != nil || saveResp.BetID != 2 || saveResp.Value != "250" {
+		t.Fatal("save winner failed with error", err, saveResp)
+	}
+	getResp, err := service.GetBetInfo(2)
+	if err != nil || text(service, getResp) != "2\tstart: 01-02-2016\tend: 02-02-2016\twinner score: 250\n\n1.\tuser2\t75\n*2.\tuser1\t100 (WINNER!)*\n*3.\tuser4\t200 (WINNER!)*\n4.\tuser3\t500\n" {
 		t.Fatal("save winner failed", err, getResp)
 	}
 }
+func TestUserRoles(t *testing.T) {
+	service := mockService()
+	client, err := openRedis()
+	defer client.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Cmd("FLUSHALL")
+
+	_, err = service.SetUserRole("omer", "tarik", "admin")
+	if err == nil || err.Error() != "You are not authorized to manage roles." {
+		t.Fatal("set role should fail", err)
+	}
+	resp, err := service.SetUserRole("sezgin", "Tarik", "admin")
+	if err != nil || text(service, resp) != "Tarik is admin now." {
+		t.Fatal("set role failed", err, resp)
+	}
+	if !service.HasPermission("tarik", "start") || service.HasPermission("tarik", "savewinner") {
+		t.Fatal("tarik should be able to start but not save winners")
+	}
+	_, err = service.SetUserRole("tarik", "omer", "owner")
+	if err == nil || err.Error() != "You cannot change roles above your own." {
+		t.Fatal("set role should fail", err)
+	}
+	resp, err = service.SetUserRole("tarik", "omer", "moderator")
+	if err != nil || text(service, resp) != "omer is moderator now." {
+		t.Fatal("set role failed", err, resp)
+	}
+	if !service.HasPermission("omer", "savefor") || !service.HasPermission("omer", "listabsent") || service.HasPermission("omer", "end") {
+		t.Fatal("omer should be able to savefor and listabsent but not end")
+	}
+	_, err = service.SetUserRole("omer", "tarik", "player")
+	if err == nil || err.Error() != "You are not authorized to manage roles." {
+		t.Fatal("set role should fail", err)
+	}
+	_, err = service.SetUserRole("tarik", "abdurrahim", "player")
+	if err == nil || err.Error() != "abdurrahim is an owner in conf, their role can only be changed from conf." {
+		t.Fatal("set role of conf owner should fail", err)
+	}
+	roles, err := service.ListRoles()
+	if err != nil || text(service, roles) != "owner: abdurrahim, sezgin\nadmin: tarik\nmoderator: omer\n" {
+		t.Fatal("list roles failed", err, roles)
+	}
+	resp, err = service.SetUserRole("sezgin", "tarik", "player")
+	if err != nil || text(service, resp) != "tarik is player now." {
+		t.Fatal("set role failed", err, resp)
+	}
+	if service.HasPermission("tarik", "start") {
+		t.Fatal("tarik should not be able to start")
+	}
+	service.Conf.Permissions = map[string]string{"start": "moderator", "savewinner": "superuser"}
+	if !service.HasPermission("omer", "start") || !service.HasPermission("sezgin", "savewinner") || service.HasPermission("omer", "savewinner") {
+		t.Fatal("permissions from conf are not applied")
+	}
+	entries, err := service.Repo.GetAuditEntries(-1)
+	if err != nil || len(entries) != 3 {
+		t.Fatal("audit log is wrong", err, entries)
+	}
+	if e := entries[0]; e.Actor != "sezgin" || e.Action != "role" || e.Target != "Tarik" || e.OldValue != "player" || e.NewValue != "admin" {
+		t.Fatal("audit entry is wrong", e)
+	}
+}
 
 type MockService struct {
 	channelMembers []string
+	mu             sync.Mutex
 	sentCallback   string
+	// sentCallbacks is every callback that is sent, sentCallback is the last one.
+	sentCallbacks []string
 }
 
 func openRedis() (*redis.Client, error) {
@@ -355,10 +601,29 @@ func (service *MockService) GetChannelMembers(channelID string) ([]string, error
 }
 
 func (service *MockService) SendCallback(text string, channel string) {
+	service.mu.Lock()
+	defer service.mu.Unlock()
 	service.sentCallback = text
+	service.sentCallbacks = append(service.sentCallbacks, text)
+}
+
+func (service *MockService) lastCallback() string {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+	return service.sentCallback
+}
+
+// text renders the result as it is posted to Slack.
+func text(service *BetService, result slackbet.Result) string {
+	return format.New(service.Conf).Text(result)
+}
+func indexPeriods(t *testing.T, service *BetService) {
+	if _, err := service.Repo.(*repo.RedisRepo).IndexPeriods(); err != nil {
+		t.Fatal(err)
+	}
 }
 func mockService() *BetService {
 	c := &slackbet.Conf{SlashCommandToken: slacktoken, Admins: []string{"sezgin", "abdurrahim"}}
-	mockService := BetService{Conf: c, Repo: &repo.RedisRepo{Url: "localhost:37564"}, SlackService: &MockService{}}
+	mockService := BetService{Conf: c, Repo: &repo.RedisRepo{Url: "localhost:37564"}, SlackService: &MockService{}, Callbacks: &sync.WaitGroup{}}
 	return &mockService
 }
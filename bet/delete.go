@@ -0,0 +1,90 @@
+package bet
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/mtyurt/slackbet"
+	"github.com/mtyurt/slackbet/repo"
+)
+
+// DeleteBet hides the bet everywhere, it can be restored later.
+func (service *BetService) DeleteBet(user string, betID int) (*slackbet.Confirmation, error) {
+	if !service.HasPermission(user, "delete") {
+		return nil, errors.New("You are not authorized to delete a bet.")
+	}
+	err := service.setBetVisibility(user, "delete", betID, repo.VisibilityDeleted)
+	if err != nil {
+		return nil, err
+	}
+	return &slackbet.Confirmation{Action: "delete", BetID: betID}, nil
+}
+
+// ArchiveBet hides the bet from lists and month lookups, it can still be seen by its ID.
+func (service *BetService) ArchiveBet(user string, betID int) (*slackbet.Confirmation, error) {
+	if !service.HasPermission(user, "archive") {
+		return nil, errors.New("You are not authorized to archive a bet.")
+	}
+	err := service.setBetVisibility(user, "archive", betID, repo.VisibilityArchived)
+	if err != nil {
+		return nil, err
+	}
+	return &slackbet.Confirmation{Action: "archive", BetID: betID}, nil
+}
+
+// RestoreBet makes a deleted or archived bet visible again.
+func (service *BetService) RestoreBet(user string, betID int) (*slackbet.Confirmation, error) {
+	if !service.HasPermission(user, "restore") {
+		return nil, errors.New("You are not authorized to restore a bet.")
+	}
+	err := service.setBetVisibility(user, "restore", betID, "")
+	if err != nil {
+		return nil, err
+	}
+	return &slackbet.Confirmation{Action: "restore", BetID: betID}, nil
+}
+
+func (service *BetService) setBetVisibility(user string, action string, betID int, visibility string) error {
+	summary, err := service.getExistingBetSummary(betID)
+	if err != nil {
+		return err
+	}
+	if summary.Status == "open" {
+		return errors.New("bet[" + strconv.Itoa(betID) + "] is still open, end it first.")
+	}
+	if summary.Visibility == visibility {
+		return errors.New("bet[" + strconv.Itoa(betID) + "] is already " + visibilityName(visibility) + ".")
+	}
+	err = service.Repo.SetBetVisibility(betID, visibility)
+	if err != nil {
+		return err
+	}
+	return service.audit(repo.AuditEntry{Actor: user, Action: action, BetID: betID, OldValue: summary.Visibility, NewValue: visibility})
+}
+
+func visibilityName(visibility string) string {
+	if visibility == "" {
+		return "visible"
+	}
+	return visibility
+}
+
+// PurgeBet removes the bet and its details for good, it can't be undone.
+func (service *BetService) PurgeBet(user string, betID int) (*slackbet.Confirmation, error) {
+	if !service.HasPermission(user, "purge") {
+		return nil, errors.New("You are not authorized to purge a bet.")
+	}
+	summary, err := service.getExistingBetSummary(betID)
+	if err != nil {
+		return nil, err
+	}
+	err = service.Repo.PurgeBet(betID)
+	if err != nil {
+		return nil, err
+	}
+	err = service.audit(repo.AuditEntry{Actor: user, Action: "purge", BetID: betID, OldValue: service.formatSummary(summary)})
+	if err != nil {
+		return nil, err
+	}
+	return &slackbet.Confirmation{Action: "purge", BetID: betID}, nil
+}
@@ -0,0 +1,119 @@
+package bet
+
+import (
+	"testing"
+
+	"github.com/mediocregopher/radix.v2/redis"
+	"github.com/mtyurt/slackbet"
+)
+
+func TestDeleteAndArchiveBets(t *testing.T) {
+	service := mockService()
+	client, err := openRedis()
+	defer client.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Cmd("FLUSHALL")
+
+	jsonStr := "[{\"User\":\"user1\",\"Number\":100,\"ExtraInfo\":\"\"}]"
+	client.Cmd("HMSET", 1, "startDate", "01-01-2016", "endDate", "02-01-2016", "status", "closed", "details", jsonStr)
+	client.Cmd("HMSET", 2, "startDate", "01-02-2016", "endDate", "02-02-2016", "status", "closed", "details", jsonStr)
+	client.Cmd("HMSET", 3, "startDate", "01-03-2016", "status", "open", "details", "[]")
+	client.Cmd("SET", "OpenBet", 3)
+	client.Cmd("SET", "LastID", 3)
+	indexPeriods(t, service)
+
+	_, err = service.DeleteBet("omer", 1)
+	if err == nil || err.Error() != "You are not authorized to delete a bet." {
+		t.Fatal("delete should fail", err)
+	}
+	_, err = service.DeleteBet("sezgin", 3)
+	if err == nil || err.Error() != "bet[3] is still open, end it first." {
+		t.Fatal("delete should fail", err)
+	}
+	resp, err := service.DeleteBet("sezgin", 1)
+	if err != nil || text(service, resp) != "deleted bet[1] successfully" {
+		t.Fatal("delete failed", err, resp)
+	}
+	resp, err = service.ArchiveBet("sezgin", 2)
+	if err != nil || text(service, resp) != "archived bet[2] successfully" {
+		t.Fatal("archive failed", err, resp)
+	}
+	list, err := service.ListBets(slackbet.ListQuery{})
+	if err != nil || text(service, list) != "3\tstart: 01-03-2016\t(still open)\n" {
+		t.Fatal("list should only contain the open bet", err, list)
+	}
+	_, err = service.GetBetInfoForPeriod("february 2016")
+	if err == nil || err.Error() != "bet for february 2016 not found." {
+		t.Fatal("archived bet should not be found by month", err)
+	}
+	_, err = service.GetBetInfo(1)
+	if err == nil || err.Error() != "No such bet exists." {
+		t.Fatal("deleted bet should not be found", err)
+	}
+	info, err := service.GetBetInfo(2)
+	if err != nil || text(service, info) != "2\tstart: 01-02-2016\tend: 02-02-2016\t(archived)\n\n1.\tuser1\t100\n" {
+		t.Fatal("archived bet should be found by id", err, info)
+	}
+	_, err = service.GetLastEndedBetInfo()
+	if err == nil || err.Error() != "No such bet exists." {
+		t.Fatal("there should be no visible ended bet", err)
+	}
+	resp, err = service.RestoreBet("sezgin", 1)
+	if err != nil || text(service, resp) != "restored bet[1] successfully" {
+		t.Fatal("restore failed", err, resp)
+	}
+	info, err = service.GetLastEndedBetInfo()
+	if err != nil || text(service, info) != "1\tstart: 01-01-2016\tend: 02-01-2016\n\n1.\tuser1\t100\n" {
+		t.Fatal("last ended bet is wrong", err, info)
+	}
+	resp, err = service.Undo("sezgin")
+	if err != nil || text(service, resp) != "reverted #3 restore by sezgin successfully" {
+		t.Fatal("undo failed", err, resp)
+	}
+	if visibility, _ := client.Cmd("HGET", 1, "visibility").Str(); visibility != "deleted" {
+		t.Fatal("bet 1 should be deleted again", visibility)
+	}
+}
+
+func TestPurgeBet(t *testing.T) {
+	service := mockService()
+	client, err := openRedis()
+	defer client.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Cmd("FLUSHALL")
+
+	client.Cmd("HMSET", 1, "startDate", "01-01-2016", "endDate", "02-01-2016", "status", "closed", "details", "[]")
+	client.Cmd("HMSET", 3, "startDate", "01-03-2016", "status", "open", "details", "[]")
+	client.Cmd("SET", "OpenBet", 3)
+	client.Cmd("SET", "LastID", 3)
+
+	_, err = service.PurgeBet("tarik", 3)
+	if err == nil || err.Error() != "You are not authorized to purge a bet." {
+		t.Fatal("purge should fail", err)
+	}
+	resp, err := service.PurgeBet("sezgin", 3)
+	if err != nil || text(service, resp) != "purged bet[3] successfully" {
+		t.Fatal("purge failed", err, resp)
+	}
+	if exists, _ := client.Cmd("EXISTS", 3).Int(); exists != 0 {
+		t.Fatal("bet 3 should not exist")
+	}
+	if !client.Cmd("GET", "OpenBet").IsType(redis.Nil) {
+		t.Fatal("there should be no open bet")
+	}
+	if lastID, _ := client.Cmd("GET", "LastID").Int(); lastID != 3 {
+		t.Fatal("last id should not be lowered", lastID)
+	}
+	service.PurgeBet("sezgin", 1)
+	if lastID, _ := client.Cmd("GET", "LastID").Int(); lastID != 3 {
+		t.Fatal("last id should not be lowered", lastID)
+	}
+	resp, err = service.StartNewBet("sezgin")
+	if err != nil || text(service, resp) != "started bet[4] successfully" {
+		t.Fatal("start failed", err, resp)
+	}
+}
@@ -0,0 +1,53 @@
+package bet
+
+import (
+	"slices"
+
+	"github.com/mtyurt/slackbet"
+	"github.com/mtyurt/slackbet/repo"
+)
+
+// GetHistory returns the winner score and the median guess of every visible closed bet that has guesses.
+func (service *BetService) GetHistory() (*slackbet.History, error) {
+	bets, err := service.closedBets()
+	if err != nil {
+		return nil, err
+	}
+	history := &slackbet.History{Bets: []slackbet.HistoryPoint{}}
+	for _, bet := range bets {
+		details, _ := qualifiedDetails(bet.Details)
+		if len(details) == 0 {
+			continue
+		}
+		numbers := make([]int, len(details))
+		for i, detail := range details {
+			numbers[i] = detail.Number
+		}
+		history.Bets = append(history.Bets, slackbet.HistoryPoint{ID: bet.ID, EndDate: bet.EndDate, WinnerScore: bet.WinnerNumber,
+			Guesses: len(numbers), MedianGuess: median(numbers)})
+	}
+	return history, nil
+}
+
+// closedBetsChunk is the number of bets that closedBets reads at a time.
+const closedBetsChunk = 50
+
+// closedBets returns the visible closed bets with their details in ascending order, details are read in a single batch.
+func (service *BetService) closedBets() ([]repo.BetWithDetails, error) {
+	var ids []int
+	err := service.walkBets(closedBetsChunk, func(summary *repo.BetSummary) bool {
+		if summary.Status == "closed" {
+			ids = append(ids, summary.ID)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.Reverse(ids)
+	bets, err := service.Repo.GetBetsWithDetails(ids)
+	if err != nil {
+		return nil, err
+	}
+	return bets, nil
+}
@@ -0,0 +1,43 @@
+package bet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mtyurt/slackbet"
+)
+
+func TestGetHistory(t *testing.T) {
+	service := mockService()
+	client, err := openRedis()
+	defer client.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Cmd("FLUSHALL")
+	client.Cmd("HMSET", 1, "startDate", "2016-01-01T00:00:00Z", "endDate", "2016-01-31T00:00:00Z", "status", "closed", "winner", 100,
+		"details", `[{"User":"omer","Number":90},{"User":"tarik","Number":150},{"User":"ali","Number":50}]`)
+	client.Cmd("HMSET", 2, "startDate", "2016-02-01T00:00:00Z", "endDate", "2016-02-28T00:00:00Z", "status", "closed", "details", "[]")
+	client.Cmd("HMSET", 3, "startDate", "2016-03-01T00:00:00Z", "endDate", "2016-03-31T00:00:00Z", "status", "closed",
+		"details", `[{"User":"omer","Number":90},{"User":"tarik","Number":211}]`)
+	client.Cmd("HMSET", 4, "startDate", "2016-04-01T00:00:00Z", "endDate", "2016-04-30T00:00:00Z", "status", "closed", "visibility", "archived",
+		"details", `[{"User":"omer","Number":90}]`)
+	client.Cmd("HMSET", 5, "startDate", "2016-05-01T00:00:00Z", "status", "open", "details", `[{"User":"omer","Number":90}]`)
+	client.Cmd("SET", "OpenBet", 5)
+	client.Cmd("SET", "LastID", 5)
+
+	history, err := service.GetHistory()
+	expected := []slackbet.HistoryPoint{
+		{ID: 1, EndDate: time.Date(2016, 1, 31, 0, 0, 0, 0, time.UTC), WinnerScore: 100, Guesses: 3, MedianGuess: 90},
+		{ID: 3, EndDate: time.Date(2016, 3, 31, 0, 0, 0, 0, time.UTC), WinnerScore: -1, Guesses: 2, MedianGuess: 150.5},
+	}
+	if err != nil || len(history.Bets) != 2 {
+		t.Fatal("history failed", err, history)
+	}
+	for i := range expected {
+		if got := history.Bets[i]; got.ID != expected[i].ID || !got.EndDate.Equal(expected[i].EndDate) || got.WinnerScore != expected[i].WinnerScore ||
+			got.Guesses != expected[i].Guesses || got.MedianGuess != expected[i].MedianGuess {
+			t.Fatal("history is wrong, expected", expected[i], "but was", got)
+		}
+	}
+}
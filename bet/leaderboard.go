@@ -0,0 +1,49 @@
+package bet
+
+import (
+	"sort"
+
+	"github.com/mtyurt/slackbet"
+)
+
+// GetLeaderboard ranks users by the visible closed bets they won, then by the fewest bets joined and by name.
+// Bets without a winner score are not counted.
+func (service *BetService) GetLeaderboard() (*slackbet.Leaderboard, error) {
+	bets, err := service.closedBets()
+	if err != nil {
+		return nil, err
+	}
+	leaderboard := &slackbet.Leaderboard{Players: []slackbet.Player{}}
+	players := make(map[string]*slackbet.Player)
+	for _, bet := range bets {
+		if bet.WinnerNumber == -1 {
+			continue
+		}
+		leaderboard.Bets++
+		for _, entry := range service.betInfo(&bet).Entries {
+			player, ok := players[entry.User]
+			if !ok {
+				player = &slackbet.Player{User: entry.User}
+				players[entry.User] = player
+			}
+			player.Bets++
+			if entry.Winner {
+				player.Wins++
+			}
+		}
+	}
+	for _, player := range players {
+		leaderboard.Players = append(leaderboard.Players, *player)
+	}
+	sort.Slice(leaderboard.Players, func(i, j int) bool {
+		a, b := leaderboard.Players[i], leaderboard.Players[j]
+		if a.Wins != b.Wins {
+			return a.Wins > b.Wins
+		}
+		if a.Bets != b.Bets {
+			return a.Bets < b.Bets
+		}
+		return a.User < b.User
+	})
+	return leaderboard, nil
+}
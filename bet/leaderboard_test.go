@@ -0,0 +1,40 @@
+package bet
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mtyurt/slackbet"
+)
+
+func TestGetLeaderboard(t *testing.T) {
+	service := mockService()
+	client, err := openRedis()
+	defer client.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Cmd("FLUSHALL")
+
+	leaderboard, err := service.GetLeaderboard()
+	if err != nil || leaderboard.Bets != 0 || len(leaderboard.Players) != 0 {
+		t.Fatal("leaderboard should be empty", err, leaderboard)
+	}
+	client.Cmd("HMSET", 1, "startDate", "01-01-2016", "endDate", "02-01-2016", "status", "closed", "winner", 100,
+		"details", `[{"User":"omer","Number":90},{"User":"tarik","Number":150}]`)
+	client.Cmd("HMSET", 2, "startDate", "01-02-2016", "endDate", "02-02-2016", "status", "closed", "winner", 200,
+		"details", `[{"User":"omer","Number":90},{"User":"tarik","Number":190},{"User":"sezgin","Number":300},{"User":"ali","Number":210}]`)
+	client.Cmd("HMSET", 3, "startDate", "01-03-2016", "endDate", "02-03-2016", "status", "closed",
+		"details", `[{"User":"ali","Number":90}]`)
+	client.Cmd("HMSET", 4, "startDate", "01-04-2016", "endDate", "02-04-2016", "status", "closed", "winner", 90, "visibility", "archived",
+		"details", `[{"User":"sezgin","Number":90},{"User":"ali","Number":10}]`)
+	client.Cmd("HMSET", 5, "startDate", "01-05-2016", "status", "open", "details", `[{"User":"sezgin","Number":90}]`)
+	client.Cmd("SET", "OpenBet", 5)
+	client.Cmd("SET", "LastID", 5)
+
+	leaderboard, err = service.GetLeaderboard()
+	expected := []slackbet.Player{{User: "ali", Wins: 1, Bets: 1}, {User: "omer", Wins: 1, Bets: 2}, {User: "tarik", Wins: 1, Bets: 2}, {User: "sezgin", Bets: 1}}
+	if err != nil || leaderboard.Bets != 2 || !reflect.DeepEqual(leaderboard.Players, expected) {
+		t.Fatal("leaderboard is wrong", err, leaderboard)
+	}
+}
@@ -0,0 +1,63 @@
+package bet
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/mtyurt/slackbet"
+	"github.com/mtyurt/slackbet/repo"
+)
+
+// ListDeadNotifications lists the callbacks that could not be delivered to Slack.
+func (service *BetService) ListDeadNotifications(user string) (*slackbet.NotificationList, error) {
+	if !service.HasPermission(user, "outbox") {
+		return nil, errors.New("You are not authorized to manage notifications.")
+	}
+	notifications, err := service.Repo.GetDeadNotifications()
+	if err != nil {
+		return nil, err
+	}
+	return &slackbet.NotificationList{Notifications: notifications}, nil
+}
+
+// ReplayNotification queues a failed notification to be delivered again, all of them if id is -1.
+func (service *BetService) ReplayNotification(user string, id int) (*slackbet.Confirmation, error) {
+	if !service.HasPermission(user, "outbox") {
+		return nil, errors.New("You are not authorized to manage notifications.")
+	}
+	var notifications []repo.Notification
+	if id == -1 {
+		dead, err := service.Repo.GetDeadNotifications()
+		if err != nil {
+			return nil, err
+		}
+		notifications = dead
+	} else {
+		notification, err := service.Repo.GetNotification(id)
+		if err != nil {
+			return nil, err
+		}
+		if notification == nil || !notification.Dead {
+			return nil, errors.New("notification #" + strconv.Itoa(id) + " has not failed.")
+		}
+		notifications = append(notifications, *notification)
+	}
+	if len(notifications) == 0 {
+		return &slackbet.Confirmation{Action: "replay"}, nil
+	}
+	for _, notification := range notifications {
+		notification.Dead = false
+		notification.Attempts = 0
+		notification.NextAttempt = time.Now()
+		err := service.Repo.SaveNotification(&notification)
+		if err != nil {
+			return nil, err
+		}
+		err = service.audit(repo.AuditEntry{Actor: user, Action: "replay", Target: "notification #" + strconv.Itoa(notification.ID)})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &slackbet.Confirmation{Action: "replay", Count: len(notifications)}, nil
+}
@@ -0,0 +1,46 @@
+package bet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mtyurt/slackbet/repo"
+)
+
+func TestReplayNotification(t *testing.T) {
+	service := mockService()
+	client, err := openRedis()
+	defer client.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Cmd("FLUSHALL")
+	created := time.Date(2016, 2, 1, 10, 0, 0, 0, time.UTC)
+	service.Repo.SaveNotification(&repo.Notification{Channel: "#general", Text: "A new bet has started!", CreatedAt: created, Attempts: 8, LastError: "slack returned channel_not_found", Dead: true})
+	service.Repo.SaveNotification(&repo.Notification{Channel: "#general", Text: "omer has placed a bet. Have you?", CreatedAt: created, NextAttempt: created})
+
+	_, err = service.ListDeadNotifications("omer")
+	if err == nil || err.Error() != "You are not authorized to manage notifications." {
+		t.Fatal("list should fail", err)
+	}
+	resp, err := service.ListDeadNotifications("sezgin")
+	if err != nil || text(service, resp) != "#1\t01-02-2016 10:00\t#general\t8 attempts\tslack returned channel_not_found\tA new bet has started!\n" {
+		t.Fatal("list failed", err, resp)
+	}
+	_, err = service.ReplayNotification("sezgin", 2)
+	if err == nil || err.Error() != "notification #2 has not failed." {
+		t.Fatal("replay should fail", err)
+	}
+	replay, err := service.ReplayNotification("sezgin", -1)
+	if err != nil || replay.Count != 1 || text(service, replay) != "queued 1 notifications again" {
+		t.Fatal("replay failed", err, replay)
+	}
+	notification, _ := service.Repo.GetNotification(1)
+	if notification.Dead || notification.Attempts != 0 {
+		t.Fatal("notification should be pending", notification)
+	}
+	resp, _ = service.ListDeadNotifications("sezgin")
+	if text(service, resp) != "there are no failed notifications." {
+		t.Fatal("list is wrong", resp)
+	}
+}
@@ -0,0 +1,123 @@
+package bet
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+
+	"github.com/mtyurt/slackbet"
+	"github.com/mtyurt/slackbet/repo"
+)
+
+// Commit returns the commitment of a sealed guess, the hex SHA-256 of "<salt>:<number>".
+func Commit(number int, salt string) string {
+	sum := sha256.Sum256([]byte(salt + ":" + strconv.Itoa(number)))
+	return hex.EncodeToString(sum[:])
+}
+
+// newSalt returns a random salt for a sealed guess.
+func newSalt() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func isCommitment(s string) bool {
+	b, err := hex.DecodeString(s)
+	return err == nil && len(b) == sha256.Size
+}
+
+// SaveSealedBet saves a commitment that user computed with Commit in the open bet, which must be sealed.
+// The server never sees the number until it is revealed with RevealBet.
+func (service *BetService) SaveSealedBet(user string, commitment string, extraInfo string) (*slackbet.Confirmation, error) {
+	if !isCommitment(commitment) {
+		return nil, errors.New("commitment should be the hex SHA-256 of <salt>:<number>.")
+	}
+	return service.saveBet(user, "seal", repo.BetDetail{User: user, ExtraInfo: extraInfo, Commitment: commitment})
+}
+
+// RevealBet reveals the sealed guess of user in the latest ended bet. Guesses can be revealed until the winner score
+// is saved, the ones that are not revealed by then are disqualified.
+func (service *BetService) RevealBet(user string, number int, salt string) (*slackbet.Confirmation, error) {
+	summaries, err := service.getBetSummaryList(2)
+	if err != nil {
+		return nil, err
+	}
+	var summary *repo.BetSummary
+	for i := range summaries {
+		if summaries[i].Status != "open" {
+			summary = &summaries[i]
+		}
+	}
+	if summary == nil {
+		return nil, errors.New("There is no ended bet to reveal.")
+	}
+	bet := strconv.Itoa(summary.ID)
+	if !summary.Sealed {
+		return nil, errors.New("Bet " + bet + " is not sealed.")
+	}
+	if summary.WinnerNumber != -1 {
+		return nil, errors.New("Winner of bet " + bet + " is saved, sealed guesses cannot be revealed anymore.")
+	}
+	details, err := service.Repo.GetBetDetails(summary.ID)
+	if err != nil {
+		return nil, err
+	}
+	i := findBet(details, user)
+	if i == -1 || details[i].Commitment == "" {
+		return nil, errors.New("You have no sealed guess in bet " + bet + ".")
+	}
+	if details[i].Salt != "" {
+		return nil, errors.New("You have already revealed your guess in bet " + bet + ".")
+	}
+	if Commit(number, salt) != details[i].Commitment {
+		return nil, errors.New("Number and salt do not match your sealed guess.")
+	}
+	details[i].Number, details[i].Salt = number, salt
+	err = service.Repo.SetBetDetail(summary.ID, details)
+	if err != nil {
+		return nil, err
+	}
+	err = service.audit(repo.AuditEntry{Actor: user, Action: "reveal", BetID: summary.ID, Target: user, OldValue: details[i].Commitment, NewValue: strconv.Itoa(number)})
+	if err != nil {
+		return nil, err
+	}
+	return &slackbet.Confirmation{Action: "reveal", BetID: summary.ID, User: user, Value: strconv.Itoa(number)}, nil
+}
+
+// qualifiedDetails returns the guesses that count and the users whose sealed guesses are not revealed
+// or don't match their commitment, like guesses that are changed in Redis after they are revealed.
+func qualifiedDetails(details []repo.BetDetail) ([]repo.BetDetail, []string) {
+	var qualified []repo.BetDetail
+	var disqualified []string
+	for _, detail := range details {
+		if detail.Commitment != "" && (detail.Salt == "" || Commit(detail.Number, detail.Salt) != detail.Commitment) {
+			disqualified = append(disqualified, detail.User)
+			continue
+		}
+		qualified = append(qualified, detail)
+	}
+	return qualified, disqualified
+}
+
+// detailValue is the guess as it is recorded in the audit log, the commitment of a sealed guess.
+func detailValue(detail repo.BetDetail) string {
+	if detail.Commitment != "" {
+		return detail.Commitment
+	}
+	return strconv.Itoa(detail.Number)
+}
+
+// findBet returns the index of the bet of user in details, -1 if there is none.
+func findBet(details []repo.BetDetail, user string) int {
+	for i, detail := range details {
+		if detail.User == user {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,125 @@
+package bet
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mtyurt/slackbet/repo"
+)
+
+func TestCommit(t *testing.T) {
+	if commitment := Commit(100, "salt"); commitment != "3b49a675437a6ff58c59028b0b030ce3d371ad950a86293c6f722a232b19518a" {
+		t.Fatal("commitment is wrong", commitment)
+	}
+	if !isCommitment(Commit(100, "salt")) || isCommitment("c0ffee") {
+		t.Fatal("commitments are not told apart")
+	}
+}
+
+func TestSealedBet(t *testing.T) {
+	service := mockService()
+	client, err := openRedis()
+	defer client.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Cmd("FLUSHALL")
+	slack := service.SlackService.(*MockService)
+
+	service.StartNewBet("sezgin")
+	if _, err = service.SaveSealedBet("omer", Commit(100, "salt"), ""); err == nil || err.Error() != "Bet 1 is not sealed, save your guess with /bet save <number>." {
+		t.Fatal("commitments should only be saved in sealed bets", err)
+	}
+	service.EndBet("sezgin")
+	service.WaitCallbacks(t.Context())
+	service.Conf.SealedBets = true
+	service.StartNewBet("sezgin")
+	service.WaitCallbacks(t.Context())
+	if callback := slack.lastCallback(); !strings.HasPrefix(callback, "A new sealed bet has started!") {
+		t.Fatal("sealed bet should be announced", callback)
+	}
+
+	sealed, err := service.SaveBet("omer", 100, "")
+	if err != nil || sealed.Action != "seal" || len(sealed.Salt) != 32 || !strings.HasSuffix(text(service, sealed), "/bet reveal 100 "+sealed.Salt) {
+		t.Fatal("guess should be sealed", err, sealed)
+	}
+	salt := sealed.Salt
+	if _, err = service.SaveBetFor("sezgin", "tarik", 250); err == nil || err.Error() != "Guesses of a sealed bet can only be saved by their users." {
+		t.Fatal("save for should fail in a sealed bet", err)
+	}
+	if _, err = service.SaveSealedBet("tarik", "c0ffee", ""); err == nil {
+		t.Fatal("invalid commitment should be rejected")
+	}
+	if sealed, err = service.SaveSealedBet("tarik", Commit(250, "tariks-salt"), "lucky"); err != nil || sealed.Salt != "" || sealed.Value != "" {
+		t.Fatal("commitment should be saved", err, sealed)
+	}
+	sealed, err = service.SaveBet("ali", 130, "")
+	if err != nil {
+		t.Fatal("guess should be sealed", err)
+	}
+	aliSalt := sealed.Salt
+	service.UnsaveBet("sezgin", "ali")
+	if _, err = service.Undo("sezgin"); err != nil {
+		t.Fatal("unsave of a sealed guess should be undone", err)
+	}
+
+	details, err := service.Repo.GetBetDetails(2)
+	expected := []repo.BetDetail{{User: "omer", Commitment: Commit(100, salt)}, {User: "tarik", ExtraInfo: "lucky", Commitment: Commit(250, "tariks-salt")},
+		{User: "ali", Commitment: Commit(130, aliSalt)}}
+	if err != nil || !reflect.DeepEqual(details, expected) {
+		t.Fatal("only commitments should be stored", err, details)
+	}
+	entries, err := service.Repo.GetAuditEntries(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if entry.Action == "seal" && !isCommitment(entry.NewValue) {
+			t.Fatal("guesses should not be audited", entry)
+		}
+	}
+	if _, err = service.RevealBet("omer", 100, salt); err == nil || err.Error() != "Bet 1 is not sealed." {
+		t.Fatal("guesses should be revealed after the bet ends", err)
+	}
+	if _, err = service.SaveWinner("sezgin", 2, 120); err == nil || err.Error() != "Bet 2 is sealed, end it before saving the winner score." {
+		t.Fatal("winner of an open sealed bet should not be saved", err)
+	}
+
+	service.WaitCallbacks(t.Context())
+	service.EndBet("sezgin")
+	service.WaitCallbacks(t.Context())
+	if callback := slack.lastCallback(); !strings.HasPrefix(callback, "bet[2] has ended, reveal your sealed guess") {
+		t.Fatal("reveals should be asked for", callback)
+	}
+	if _, err = service.RevealBet("omer", 101, salt); err == nil || err.Error() != "Number and salt do not match your sealed guess." {
+		t.Fatal("wrong number should not be revealed", err)
+	}
+	revealed, err := service.RevealBet("omer", 100, salt)
+	if err != nil || text(service, revealed) != "revealed your guess 100 for bet[2] successfully" {
+		t.Fatal("guess should be revealed", err, revealed)
+	}
+	if _, err = service.RevealBet("omer", 100, salt); err == nil || err.Error() != "You have already revealed your guess in bet 2." {
+		t.Fatal("guess should be revealed once", err)
+	}
+	if _, err = service.RevealBet("sezgin", 100, salt); err == nil || err.Error() != "You have no sealed guess in bet 2." {
+		t.Fatal("users without a guess should not reveal", err)
+	}
+	service.RevealBet("tarik", 250, "tariks-salt")
+	details, _ = service.Repo.GetBetDetails(2)
+	details[1].Number = 120
+	service.Repo.SetBetDetail(2, details)
+
+	service.SaveWinner("sezgin", 2, 120)
+	service.WaitCallbacks(t.Context())
+	info, err := service.GetBetInfo(2)
+	if err != nil || len(info.Entries) != 1 || info.Entries[0].User != "omer" || !reflect.DeepEqual(info.Disqualified, []string{"tarik", "ali"}) {
+		t.Fatal("unrevealed and changed guesses should be disqualified", err, info)
+	}
+	if callback := slack.lastCallback(); !strings.Contains(callback, "disqualified: tarik, ali") {
+		t.Fatal("results should be posted when the winner score is saved", callback)
+	}
+	if _, err = service.RevealBet("ali", 130, aliSalt); err == nil || err.Error() != "Winner of bet 2 is saved, sealed guesses cannot be revealed anymore." {
+		t.Fatal("guesses should not be revealed after the winner score", err)
+	}
+}
@@ -0,0 +1,49 @@
+package bet
+
+import (
+	"math"
+	"sort"
+
+	"github.com/mtyurt/slackbet"
+)
+
+// guessStats summarizes the entries of a closed bet, nil is returned if there are no entries.
+func guessStats(bet *slackbet.BetInfo) *slackbet.GuessStats {
+	if len(bet.Entries) == 0 {
+		return nil
+	}
+	numbers := make([]int, len(bet.Entries))
+	stats := &slackbet.GuessStats{BetID: bet.ID, Count: len(numbers), Min: bet.Entries[0].Number, Max: bet.Entries[0].Number, WinnerScore: bet.WinnerScore}
+	sum := 0.0
+	for i, entry := range bet.Entries {
+		numbers[i] = entry.Number
+		stats.Min, stats.Max = min(stats.Min, entry.Number), max(stats.Max, entry.Number)
+		sum += float64(entry.Number)
+	}
+	stats.Mean = sum / float64(len(numbers))
+	variance := 0.0
+	for _, n := range numbers {
+		variance += (float64(n) - stats.Mean) * (float64(n) - stats.Mean)
+	}
+	stats.StdDev = math.Sqrt(variance / float64(len(numbers)))
+	stats.Median = median(numbers)
+	if bet.WinnerScore != -1 {
+		stats.MedianError = math.Abs(stats.Median - float64(bet.WinnerScore))
+	}
+	return stats
+}
+
+// median returns the median of numbers, the mean of the two middle numbers if there is an even count.
+func median(numbers []int) float64 {
+	if len(numbers) == 0 {
+		return 0
+	}
+	sorted := make([]int, len(numbers))
+	copy(sorted, numbers)
+	sort.Ints(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return float64(sorted[middle-1]+sorted[middle]) / 2
+	}
+	return float64(sorted[middle])
+}
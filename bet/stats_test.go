@@ -0,0 +1,39 @@
+package bet
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/mtyurt/slackbet"
+)
+
+func TestGuessStats(t *testing.T) {
+	bet := &slackbet.BetInfo{ID: 2, WinnerScore: -1, Entries: []slackbet.Entry{
+		{User: "user2", Number: 75}, {User: "user1", Number: 100}, {User: "user5", Number: 120}, {User: "user3", Number: 175}, {User: "user4", Number: 275}}}
+	stats := guessStats(bet)
+	if stats.BetID != 2 || stats.Count != 5 || stats.Min != 75 || stats.Max != 275 || stats.Mean != 149 || stats.Median != 120 ||
+		math.Abs(stats.StdDev-71.09) > 0.01 || stats.WinnerScore != -1 || stats.MedianError != 0 {
+		t.Fatal("stats are wrong", stats)
+	}
+	bet.WinnerScore = 130
+	if stats = guessStats(bet); stats.WinnerScore != 130 || stats.MedianError != 10 {
+		t.Fatal("median error is wrong", stats)
+	}
+	if stats = guessStats(&slackbet.BetInfo{ID: 3, Entries: []slackbet.Entry{}}); stats != nil {
+		t.Fatal("bet without entries should have no stats", stats)
+	}
+}
+
+func TestMedian(t *testing.T) {
+	numbers := []int{5, 1, 3}
+	if m := median(numbers); m != 3 || !reflect.DeepEqual(numbers, []int{5, 1, 3}) {
+		t.Fatal("median is wrong", m, numbers)
+	}
+	if m := median([]int{4, 1, 3, 2}); m != 2.5 {
+		t.Fatal("median of even count is wrong", m)
+	}
+	if m := median(nil); m != 0 {
+		t.Fatal("median of nothing should be 0", m)
+	}
+}
@@ -0,0 +1,284 @@
+package bet
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/mtyurt/slackbet"
+	"github.com/mtyurt/slackbet/repo"
+)
+
+// undoableActions are the audited admin actions that Undo can revert, player saves and starts are not included.
+var undoableActions = map[string]bool{
+	"end":         true,
+	"reopen":      true,
+	"savefor":     true,
+	"unsave":      true,
+	"savewinner":  true,
+	"clearwinner": true,
+	"role":        true,
+	"delete":      true,
+	"archive":     true,
+	"restore":     true,
+}
+
+// ReopenBet marks an ended bet as open again, there must be no other open bet and no winner score.
+// The end date is recorded in the audit entry, so that undo can end the bet on it again.
+func (service *BetService) ReopenBet(user string, betID int) (*slackbet.Confirmation, error) {
+	if !service.HasPermission(user, "reopen") {
+		return nil, errors.New("You are not authorized to reopen a bet.")
+	}
+	summary, err := service.reopenBet(betID)
+	if err != nil {
+		return nil, err
+	}
+	err = service.audit(repo.AuditEntry{Actor: user, Action: "reopen", BetID: betID, OldValue: summary.EndDate.Format(time.RFC3339), NewValue: "open"})
+	if err != nil {
+		return nil, err
+	}
+	return &slackbet.Confirmation{Action: "reopen", BetID: betID}, nil
+}
+
+// reopenBet reopens the bet and returns its summary from before.
+func (service *BetService) reopenBet(betID int) (*repo.BetSummary, error) {
+	summary, err := service.getExistingBetSummary(betID)
+	if err != nil {
+		return nil, err
+	}
+	if summary.Status == "open" {
+		return nil, errors.New("bet[" + strconv.Itoa(betID) + "] is already open.")
+	}
+	if summary.WinnerNumber != -1 {
+		return nil, errors.New("bet[" + strconv.Itoa(betID) + "] has a winner score, clear the winner first.")
+	}
+	openBetID, err := service.Repo.GetIDOfOpenBet()
+	if err != nil {
+		return nil, err
+	}
+	if openBetID != -1 {
+		return nil, errors.New("There is a bet in progress, please finish it first.")
+	}
+	return summary, service.Repo.ReopenBet(betID)
+}
+
+// UnsaveBet removes the bet of user from the open bet.
+func (service *BetService) UnsaveBet(actor string, user string) (*slackbet.Confirmation, error) {
+	if !service.HasPermission(actor, "unsave") {
+		return nil, errors.New("You are not authorized to remove a bet.")
+	}
+	openBetID, err := service.Repo.GetIDOfOpenBet()
+	if err != nil {
+		return nil, err
+	}
+	if openBetID == -1 {
+		return nil, errors.New("There is no active bet right now.")
+	}
+	details, err := service.Repo.GetBetDetails(openBetID)
+	if err != nil {
+		return nil, err
+	}
+	i := findBet(details, user)
+	if i == -1 {
+		return nil, errors.New(user + " has not placed a bet.")
+	}
+	err = service.Repo.SetBetDetail(openBetID, removeBetFromList(details, user))
+	if err != nil {
+		return nil, err
+	}
+	err = service.audit(repo.AuditEntry{Actor: actor, Action: "unsave", BetID: openBetID, Target: user, OldValue: detailValue(details[i]),
+		ExtraInfo: details[i].ExtraInfo})
+	if err != nil {
+		return nil, err
+	}
+	return &slackbet.Confirmation{Action: "unsave", BetID: openBetID, User: user}, nil
+}
+
+// ClearWinner removes the winner score of the bet.
+func (service *BetService) ClearWinner(user string, betID int) (*slackbet.Confirmation, error) {
+	if !service.HasPermission(user, "clearwinner") {
+		return nil, errors.New("You are not authorized to clear a winner.")
+	}
+	summary, err := service.getExistingBetSummary(betID)
+	if err != nil {
+		return nil, err
+	}
+	if summary.WinnerNumber == -1 {
+		return nil, errors.New("bet[" + strconv.Itoa(betID) + "] has no winner score.")
+	}
+	err = service.Repo.ClearBetWinner(betID)
+	if err != nil {
+		return nil, err
+	}
+	err = service.audit(repo.AuditEntry{Actor: user, Action: "clearwinner", BetID: betID, OldValue: strconv.Itoa(summary.WinnerNumber)})
+	if err != nil {
+		return nil, err
+	}
+	return &slackbet.Confirmation{Action: "clearwinner", BetID: betID}, nil
+}
+
+// Undo reverts the latest admin action in the audit log that is not reverted yet.
+// The action is only reverted if the state it changed is not changed by someone else since,
+// older actions are not reverted while it cannot be.
+func (service *BetService) Undo(user string) (*slackbet.Confirmation, error) {
+	if !service.HasPermission(user, "undo") {
+		return nil, errors.New("You are not authorized to undo.")
+	}
+	entries, err := service.Repo.GetAuditEntries(-1)
+	if err != nil {
+		return nil, err
+	}
+	entry := lastUndoableEntry(entries)
+	if entry == nil {
+		return nil, errors.New("There is nothing to undo.")
+	}
+	err = service.checkUndo(user, *entry)
+	if err != nil {
+		return nil, err
+	}
+	err = service.revert(*entry)
+	if err != nil {
+		return nil, errors.New("cannot undo #" + strconv.Itoa(entry.ID) + ": " + err.Error())
+	}
+	err = service.audit(repo.AuditEntry{Actor: user, Action: "undo", BetID: entry.BetID, Target: entry.Target,
+		OldValue: entry.NewValue, NewValue: entry.OldValue, Reverts: entry.ID})
+	if err != nil {
+		return nil, err
+	}
+	return &slackbet.Confirmation{Action: "undo", BetID: entry.BetID, Reverted: entry}, nil
+}
+
+// checkUndo returns why user cannot undo entry, role changes are checked like SetUserRole.
+func (service *BetService) checkUndo(user string, entry repo.AuditEntry) error {
+	if !service.HasPermission(user, entry.Action) {
+		return errors.New("You are not authorized to undo " + entry.Action + ".")
+	}
+	if entry.Action != "role" {
+		return nil
+	}
+	if service.isConfOwner(entry.Target) {
+		return errors.New(entry.Target + " is an owner in conf, their role can only be changed from conf.")
+	}
+	oldRole, err := slackbet.ParseRole(entry.OldValue)
+	if err != nil {
+		return err
+	}
+	newRole, err := slackbet.ParseRole(entry.NewValue)
+	if err != nil {
+		return err
+	}
+	actorRole := service.GetUserRole(user)
+	if oldRole > actorRole || newRole > actorRole {
+		return errors.New("You cannot change roles above your own.")
+	}
+	return nil
+}
+
+func lastUndoableEntry(entries []repo.AuditEntry) *repo.AuditEntry {
+	reverted := make(map[int]bool)
+	for _, entry := range entries {
+		if entry.Reverts != 0 {
+			reverted[entry.Reverts] = true
+		}
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		if undoableActions[entries[i].Action] && !reverted[entries[i].ID] {
+			return &entries[i]
+		}
+	}
+	return nil
+}
+
+func (service *BetService) revert(entry repo.AuditEntry) error {
+	switch entry.Action {
+	case "end":
+		_, err := service.reopenBet(entry.BetID)
+		return err
+	case "reopen":
+		openBetID, err := service.Repo.GetIDOfOpenBet()
+		if err != nil {
+			return err
+		}
+		if openBetID != entry.BetID {
+			return errors.New("bet[" + strconv.Itoa(entry.BetID) + "] is not open.")
+		}
+		endDate, err := time.Parse(time.RFC3339, entry.OldValue)
+		if err != nil {
+			// reopen entries recorded before the end date was kept have "closed" in OldValue.
+			endDate = time.Now().In(service.Conf.Location())
+		}
+		return service.Repo.SetBetAsEnded(entry.BetID, endDate)
+	case "savefor", "unsave":
+		return service.revertBetDetail(entry)
+	case "savewinner", "clearwinner":
+		summary, err := service.getExistingBetSummary(entry.BetID)
+		if err != nil {
+			return err
+		}
+		if scoreString(summary.WinnerNumber) != entry.NewValue {
+			return errors.New("winner score is changed since.")
+		}
+		if entry.OldValue == "" {
+			return service.Repo.ClearBetWinner(entry.BetID)
+		}
+		oldWinner, err := strconv.Atoi(entry.OldValue)
+		if err != nil {
+			return err
+		}
+		return service.Repo.SetBetWinner(entry.BetID, oldWinner)
+	case "role":
+		if service.GetUserRole(entry.Target).String() != entry.NewValue {
+			return errors.New("role of " + entry.Target + " is changed since.")
+		}
+		if entry.OldValue == slackbet.RolePlayer.String() {
+			return service.Repo.RemoveUserRole(entry.Target)
+		}
+		return service.Repo.SetUserRole(entry.Target, entry.OldValue)
+	case "delete", "archive", "restore":
+		summary, err := service.getExistingBetSummary(entry.BetID)
+		if err != nil {
+			return err
+		}
+		if summary.Visibility != entry.NewValue {
+			return errors.New("visibility of bet[" + strconv.Itoa(entry.BetID) + "] is changed since.")
+		}
+		return service.Repo.SetBetVisibility(entry.BetID, entry.OldValue)
+	}
+	return errors.New(entry.Action + " cannot be undone.")
+}
+
+// revertBetDetail puts the bet of entry.Target back to entry.OldValue with entry.ExtraInfo, removing it if there was no bet.
+func (service *BetService) revertBetDetail(entry repo.AuditEntry) error {
+	details, err := service.Repo.GetBetDetails(entry.BetID)
+	if err != nil {
+		return err
+	}
+	current := ""
+	if i := findBet(details, entry.Target); i != -1 {
+		current = detailValue(details[i])
+	}
+	if current != entry.NewValue {
+		return errors.New("bet of " + entry.Target + " is changed since.")
+	}
+	if entry.OldValue == "" {
+		return service.Repo.SetBetDetail(entry.BetID, removeBetFromList(details, entry.Target))
+	}
+	if isCommitment(entry.OldValue) {
+		return service.Repo.SetBetDetail(entry.BetID, appendBetToList(details, repo.BetDetail{User: entry.Target, ExtraInfo: entry.ExtraInfo, Commitment: entry.OldValue}))
+	}
+	number, err := strconv.Atoi(entry.OldValue)
+	if err != nil {
+		return err
+	}
+	return service.Repo.SetBetDetail(entry.BetID, appendBetToList(details, repo.BetDetail{User: entry.Target, Number: number, ExtraInfo: entry.ExtraInfo}))
+}
+
+func removeBetFromList(list []repo.BetDetail, user string) []repo.BetDetail {
+	newList := make([]repo.BetDetail, 0, len(list))
+	for _, elem := range list {
+		if elem.User != user {
+			newList = append(newList, elem)
+		}
+	}
+	return newList
+}
@@ -0,0 +1,244 @@
+package bet
+
+import (
+	"testing"
+
+	"github.com/mtyurt/slackbet"
+	"github.com/mtyurt/slackbet/repo"
+)
+
+func TestCorrections(t *testing.T) {
+	service := mockService()
+	client, err := openRedis()
+	defer client.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Cmd("FLUSHALL")
+
+	service.StartNewBet("sezgin")
+	service.SaveBet("omer", 100, "")
+	service.SaveBetFor("sezgin", "tarrik", 90)
+	_, err = service.UnsaveBet("omer", "tarrik")
+	if err == nil || err.Error() != "You are not authorized to remove a bet." {
+		t.Fatal("unsave should fail", err)
+	}
+	resp, err := service.UnsaveBet("sezgin", "tarrik")
+	if err != nil || text(service, resp) != "removed bet of tarrik successfully" {
+		t.Fatal("unsave failed", err, resp)
+	}
+	if details, _ := client.Cmd("HGET", 1, "details").Str(); details != "[{\"User\":\"omer\",\"Number\":100,\"ExtraInfo\":\"\"}]" {
+		t.Fatal("details are wrong", details)
+	}
+	_, err = service.UnsaveBet("sezgin", "tarrik")
+	if err == nil || err.Error() != "tarrik has not placed a bet." {
+		t.Fatal("unsave should fail", err)
+	}
+
+	service.EndBet("sezgin")
+	resp, err = service.ReopenBet("sezgin", 1)
+	if err != nil || text(service, resp) != "reopened bet[1] successfully" {
+		t.Fatal("reopen failed", err, resp)
+	}
+	if status, _ := client.Cmd("HGET", 1, "status").Str(); status != "open" {
+		t.Fatal("bet should be open", status)
+	}
+	if openBetID, _ := client.Cmd("GET", "OpenBet").Int(); openBetID != 1 {
+		t.Fatal("open bet is wrong", openBetID)
+	}
+	_, err = service.ReopenBet("sezgin", 1)
+	if err == nil || err.Error() != "bet[1] is already open." {
+		t.Fatal("reopen should fail", err)
+	}
+	service.EndBet("sezgin")
+
+	service.SaveWinner("sezgin", 1, 110)
+	_, err = service.ReopenBet("sezgin", 1)
+	if err == nil || err.Error() != "bet[1] has a winner score, clear the winner first." {
+		t.Fatal("reopen should fail", err)
+	}
+	resp, err = service.ClearWinner("sezgin", 1)
+	if err != nil || text(service, resp) != "winner of bet[1] is cleared successfully" {
+		t.Fatal("clear winner failed", err, resp)
+	}
+	if exists, _ := client.Cmd("HEXISTS", 1, "winner").Int(); exists != 0 {
+		t.Fatal("winner should be cleared")
+	}
+	_, err = service.ClearWinner("sezgin", 1)
+	if err == nil || err.Error() != "bet[1] has no winner score." {
+		t.Fatal("clear winner should fail", err)
+	}
+}
+
+func TestUndo(t *testing.T) {
+	service := mockService()
+	client, err := openRedis()
+	defer client.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Cmd("FLUSHALL")
+
+	_, err = service.Undo("sezgin")
+	if err == nil || err.Error() != "There is nothing to undo." {
+		t.Fatal("undo should fail", err)
+	}
+	service.StartNewBet("sezgin")
+	service.SaveBetFor("sezgin", "omer", 100)
+	service.SaveBetFor("sezgin", "omer", 120)
+	service.EndBet("sezgin")
+	service.SetUserRole("sezgin", "tarik", "admin")
+	service.SaveWinner("sezgin", 1, 110)
+	service.SaveWinner("sezgin", 1, 115)
+
+	_, err = service.Undo("omer")
+	if err == nil || err.Error() != "You are not authorized to undo." {
+		t.Fatal("undo should fail", err)
+	}
+	_, err = service.Undo("tarik")
+	if err == nil || err.Error() != "You are not authorized to undo savewinner." {
+		t.Fatal("undo should fail", err)
+	}
+	resp, err := service.Undo("sezgin")
+	if err != nil || text(service, resp) != "reverted #7 savewinner by sezgin successfully" {
+		t.Fatal("undo failed", err, resp)
+	}
+	if winner, _ := client.Cmd("HGET", 1, "winner").Int(); winner != 110 {
+		t.Fatal("winner should be reverted", winner)
+	}
+	service.Undo("sezgin")
+	if exists, _ := client.Cmd("HEXISTS", 1, "winner").Int(); exists != 0 {
+		t.Fatal("winner should be cleared")
+	}
+	resp, err = service.Undo("sezgin")
+	if err != nil || text(service, resp) != "reverted #5 role by sezgin successfully" {
+		t.Fatal("undo failed", err, resp)
+	}
+	if service.HasPermission("tarik", "undo") {
+		t.Fatal("tarik should not be an admin anymore")
+	}
+	resp, err = service.Undo("sezgin")
+	if err != nil || text(service, resp) != "reverted #4 end by sezgin successfully" {
+		t.Fatal("undo failed", err, resp)
+	}
+	if openBetID, _ := client.Cmd("GET", "OpenBet").Int(); openBetID != 1 {
+		t.Fatal("bet should be open again", openBetID)
+	}
+	service.SaveBet("omer", 130, "")
+	_, err = service.Undo("sezgin")
+	if err == nil || err.Error() != "cannot undo #3: bet of omer is changed since." {
+		t.Fatal("undo should fail", err)
+	}
+	service.SaveBet("omer", 120, "")
+	service.Undo("sezgin")
+	resp, err = service.Undo("sezgin")
+	if err != nil || text(service, resp) != "reverted #2 savefor by sezgin successfully" {
+		t.Fatal("undo failed", err, resp)
+	}
+	if details, _ := client.Cmd("HGET", 1, "details").Str(); details != "[]" {
+		t.Fatal("details are wrong", details)
+	}
+	_, err = service.Undo("sezgin")
+	if err == nil || err.Error() != "There is nothing to undo." {
+		t.Fatal("undo should fail", err)
+	}
+}
+
+func TestUndoRoleAboveOwnRole(t *testing.T) {
+	service := mockService()
+	client, err := openRedis()
+	defer client.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Cmd("FLUSHALL")
+
+	service.SetUserRole("sezgin", "tarik", "admin")
+	service.SetUserRole("sezgin", "omer", "owner")
+	_, err = service.Undo("tarik")
+	if err == nil || err.Error() != "You cannot change roles above your own." {
+		t.Fatal("admin should not undo a promotion to owner", err)
+	}
+	service.SetUserRole("sezgin", "omer", "player")
+	_, err = service.Undo("tarik")
+	if err == nil || err.Error() != "You cannot change roles above your own." {
+		t.Fatal("admin should not undo a demotion of an owner", err)
+	}
+	if role := service.GetUserRole("omer"); role != slackbet.RolePlayer {
+		t.Fatal("role should not be changed", role)
+	}
+	if _, err = service.Undo("sezgin"); err != nil || service.GetUserRole("omer") != slackbet.RoleOwner {
+		t.Fatal("owner should undo the demotion", err)
+	}
+}
+
+func TestUndoStopsAtEntryThatCannotBeReverted(t *testing.T) {
+	service := mockService()
+	client, err := openRedis()
+	defer client.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Cmd("FLUSHALL")
+
+	service.StartNewBet("sezgin")
+	service.EndBet("sezgin")
+	service.SaveWinner("sezgin", 1, 110)
+	service.Repo.SetBetWinner(1, 200)
+
+	for i := 0; i < 2; i++ {
+		_, err = service.Undo("sezgin")
+		if err == nil || err.Error() != "cannot undo #3: winner score is changed since." {
+			t.Fatal("undo should fail", err)
+		}
+	}
+	if status, _ := client.Cmd("HGET", 1, "status").Str(); status != "closed" {
+		t.Fatal("bet should still be closed", status)
+	}
+}
+
+func TestUndoUnsaveRestoresExtraInfo(t *testing.T) {
+	service := mockService()
+	client, err := openRedis()
+	defer client.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Cmd("FLUSHALL")
+
+	service.StartNewBet("sezgin")
+	service.SaveBet("omer", 100, "lucky guess")
+	service.UnsaveBet("sezgin", "omer")
+	if _, err = service.Undo("sezgin"); err != nil {
+		t.Fatal("undo failed", err)
+	}
+	details, err := service.Repo.GetBetDetails(1)
+	if err != nil || len(details) != 1 || details[0] != (repo.BetDetail{User: "omer", Number: 100, ExtraInfo: "lucky guess"}) {
+		t.Fatal("bet should be restored with its extra info", err, details)
+	}
+}
+
+func TestUndoReopenKeepsEndDate(t *testing.T) {
+	service := mockService()
+	client, err := openRedis()
+	defer client.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Cmd("FLUSHALL")
+
+	client.Cmd("HMSET", 1, "startDate", "2016-03-01T00:00:00Z", "endDate", "2016-03-20T00:00:00Z", "status", "closed", "details", "[]")
+	client.Cmd("SET", "LastID", 1)
+	indexPeriods(t, service)
+	service.ReopenBet("sezgin", 1)
+	if _, err = service.Undo("sezgin"); err != nil {
+		t.Fatal("undo failed", err)
+	}
+	if endDate, _ := client.Cmd("HGET", 1, "endDate").Str(); endDate != "2016-03-20T00:00:00Z" {
+		t.Fatal("end date should be restored", endDate)
+	}
+	info, err := service.GetBetInfoForPeriod("march 2016")
+	if err != nil || info == nil || info.ID != 1 {
+		t.Fatal("bet should stay in its period", err, info)
+	}
+}
@@ -0,0 +1,78 @@
+package bet
+
+import (
+	"strconv"
+
+	"github.com/mtyurt/slackbet"
+	"github.com/mtyurt/slackbet/repo"
+)
+
+// hiddenValue replaces guesses in audit entries that the viewer cannot see.
+const hiddenValue = "hidden"
+
+// Reveal decides how much of the guesses of a bet with status viewer can see. Guesses of closed bets are public,
+// guesses of the open bet are shown to users with the seeguesses permission and everyone else sees Conf.OpenBetReveal.
+// viewer is empty for the channel and other places that everyone can read.
+func (service *BetService) Reveal(viewer string, status string) slackbet.Reveal {
+	if status != "open" {
+		return slackbet.RevealAll
+	}
+	if viewer != "" && service.HasPermission(viewer, "seeguesses") {
+		return slackbet.RevealAll
+	}
+	reveal, err := slackbet.ParseReveal(service.Conf.OpenBetReveal)
+	if err != nil || reveal == slackbet.RevealAll {
+		return slackbet.RevealParticipants
+	}
+	return reveal
+}
+
+// placedBetText is the callback posted when user places a bet in the open bet, count is the number of users who placed a bet.
+// It is empty if nothing should be posted.
+func (service *BetService) placedBetText(user string, count int) string {
+	switch service.Reveal("", "open") {
+	case slackbet.RevealParticipants:
+		return user + " has placed a bet. Have you?"
+	case slackbet.RevealCount:
+		return strconv.Itoa(count) + " people have placed a bet. Have you?"
+	}
+	return ""
+}
+
+// revealAuditEntries hides the guesses in entries of the open bet that viewer cannot see, entries are not modified.
+// If the participants are hidden too, targets are blanked and the entries whose actor is a participant are dropped.
+func (service *BetService) revealAuditEntries(viewer string, entries []repo.AuditEntry) ([]repo.AuditEntry, error) {
+	reveal := service.Reveal(viewer, "open")
+	if reveal == slackbet.RevealAll {
+		return entries, nil
+	}
+	openBetID, err := service.Repo.GetIDOfOpenBet()
+	if err != nil || openBetID == -1 {
+		return entries, err
+	}
+	revealed := make([]repo.AuditEntry, 0, len(entries))
+	for _, entry := range entries {
+		if entry.BetID == openBetID && entry.Target != "" {
+			if reveal < slackbet.RevealParticipants {
+				if participantActions[entry.Action] {
+					continue
+				}
+				entry.Target = ""
+			}
+			entry.OldValue, entry.NewValue, entry.ExtraInfo = hideValue(entry.OldValue), hideValue(entry.NewValue), hideValue(entry.ExtraInfo)
+		}
+		revealed = append(revealed, entry)
+	}
+	return revealed, nil
+}
+
+// participantActions are the audit actions that participants of a bet can take on their own bets.
+var participantActions = map[string]bool{"save": true, "seal": true, "unsave": true}
+
+// hideValue hides a guess, an empty value stays empty since it tells only that there was no guess.
+func hideValue(value string) string {
+	if value == "" {
+		return ""
+	}
+	return hiddenValue
+}
@@ -0,0 +1,173 @@
+package bet
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/mtyurt/slackbet"
+	"github.com/mtyurt/slackbet/format"
+)
+
+// rendered returns the result in every format that it can be sent in.
+func rendered(t *testing.T, service *BetService, result slackbet.Result) string {
+	f := format.New(service.Conf)
+	data, err := json.Marshal(f.JSON(result))
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocks, err := json.Marshal(f.Blocks(result))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f.Text(result) + f.Table(result) + string(data) + string(blocks)
+}
+
+func TestOpenBetGuessesAreHidden(t *testing.T) {
+	service := mockService()
+	client, err := openRedis()
+	defer client.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Cmd("FLUSHALL")
+	slack := service.SlackService.(*MockService)
+	slack.channelMembers = []string{"omer", "tarik", "mod", "ali"}
+	service.SetUserRole("sezgin", "mod", "moderator")
+
+	service.StartNewBet("sezgin")
+	own, err := service.SaveBet("omer", 48151, "")
+	if err != nil || own.Value != "48151" {
+		t.Fatal("own guess should be confirmed", err, own)
+	}
+	savedFor, err := service.SaveBetFor("mod", "tarik", 62342)
+	if err != nil || savedFor.Value != "" {
+		t.Fatal("guess saved for someone else should be hidden from a moderator", err, savedFor)
+	}
+	indexPeriods(t, service)
+
+	results := []slackbet.Result{savedFor}
+	calls := map[string]func() (slackbet.Result, error){
+		"latest bet": func() (slackbet.Result, error) { return service.GetBetInfo(-1) },
+		"bet":        func() (slackbet.Result, error) { return service.GetBetInfo(1) },
+		"period":     func() (slackbet.Result, error) { return service.GetBetInfoForPeriod("this month") },
+		"list":       func() (slackbet.Result, error) { return service.ListBets(slackbet.ListQuery{}) },
+		"whowins":    func() (slackbet.Result, error) { return service.CalculateWhoWins(50000) },
+		"absent":     func() (slackbet.Result, error) { return service.ListAbsentUsers("mod") },
+		"audit":      func() (slackbet.Result, error) { return service.GetAuditLog("mod", -1) },
+		"bet audit":  func() (slackbet.Result, error) { return service.GetAuditLog("mod", 1) },
+		"export":     func() (slackbet.Result, error) { return service.ExportAuditLog("mod", 1) },
+		"leaders":    func() (slackbet.Result, error) { return service.GetLeaderboard() },
+		"history":    func() (slackbet.Result, error) { return service.GetHistory() },
+	}
+	for name, call := range calls {
+		result, err := call()
+		if err != nil {
+			t.Fatal(name, "failed", err)
+		}
+		results = append(results, result)
+	}
+	service.WaitCallbacks(t.Context())
+	for _, result := range results {
+		out := rendered(t, service, result)
+		if strings.Contains(out, "48151") || strings.Contains(out, "62342") {
+			t.Fatal("guesses of the open bet are exposed", out)
+		}
+	}
+	for _, callback := range slack.sentCallbacks {
+		if strings.Contains(callback, "48151") || strings.Contains(callback, "62342") {
+			t.Fatal("guesses of the open bet are posted", callback)
+		}
+	}
+	if !strings.Contains(strings.Join(slack.sentCallbacks, "\n"), "tarik has placed a bet. Have you?") {
+		t.Fatal("participants should be posted", slack.sentCallbacks)
+	}
+
+	log, err := service.GetAuditLog("sezgin", 1)
+	if err != nil || !strings.Contains(text(service, log), "62342") {
+		t.Fatal("admins should see the guesses in the audit log", err, log)
+	}
+	service.EndBet("sezgin")
+	service.WaitCallbacks(t.Context())
+	info, err := service.GetBetInfo(1)
+	if out := rendered(t, service, info); err != nil || !strings.Contains(out, "48151") || !strings.Contains(out, "62342") {
+		t.Fatal("guesses of a closed bet should be public", err, out)
+	}
+	if log, err = service.GetAuditLog("mod", 1); err != nil || !strings.Contains(text(service, log), "62342") {
+		t.Fatal("audit log of a closed bet should show the guesses", err, log)
+	}
+}
+
+func TestOpenBetReveal(t *testing.T) {
+	service := mockService()
+	client, err := openRedis()
+	defer client.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Cmd("FLUSHALL")
+	slack := service.SlackService.(*MockService)
+	slack.channelMembers = []string{"omer", "tarik"}
+
+	if reveal := service.Reveal("omer", "open"); reveal != slackbet.RevealParticipants {
+		t.Fatal("participants should be revealed by default", reveal)
+	}
+	if reveal := service.Reveal("sezgin", "open"); reveal != slackbet.RevealAll {
+		t.Fatal("admins should see every guess", reveal)
+	}
+	if reveal := service.Reveal("", "closed"); reveal != slackbet.RevealAll {
+		t.Fatal("guesses of closed bets should be public", reveal)
+	}
+	service.Conf.Permissions = map[string]string{"seeguesses": "player"}
+	if reveal := service.Reveal("omer", "open"); reveal != slackbet.RevealAll {
+		t.Fatal("users with the seeguesses permission should see every guess", reveal)
+	}
+	service.Conf.Permissions = nil
+
+	service.Conf.OpenBetReveal = "count"
+	service.StartNewBet("sezgin")
+	service.SaveBet("omer", 100, "")
+	service.WaitCallbacks(t.Context())
+	service.SaveBet("tarik", 200, "")
+	service.WaitCallbacks(t.Context())
+	if callback := slack.lastCallback(); callback != "2 people have placed a bet. Have you?" {
+		t.Fatal("count should be posted", callback)
+	}
+	info, err := service.GetBetInfo(1)
+	if err != nil || info.EntryCount != 2 || info.Entries != nil {
+		t.Fatal("count should be revealed", err, info)
+	}
+	if count, err := service.CountOpenBetParticipants(); err != nil || count != 2 {
+		t.Fatal("count should be reported", err, count)
+	}
+	if _, err = service.ListAbsentUsers("sezgin"); err == nil || err.Error() != "Participants of the open bet are hidden." {
+		t.Fatal("absent users should be hidden", err)
+	}
+
+	service.Conf.OpenBetReveal = "nothing"
+	slack.sentCallbacks = nil
+	service.SaveBet("ali", 300, "")
+	service.WaitCallbacks(t.Context())
+	if len(slack.sentCallbacks) != 0 {
+		t.Fatal("nothing should be posted", slack.sentCallbacks)
+	}
+	info, err = service.GetBetInfo(1)
+	if out := rendered(t, service, info); err != nil || info.EntryCount != -1 || strings.Contains(out, "entryCount") || strings.Contains(out, "3 guesses") {
+		t.Fatal("count should be hidden", err, out)
+	}
+	if count, err := service.CountOpenBetParticipants(); err != nil || count != -1 {
+		t.Fatal("count should not be reported", err, count)
+	}
+	service.SetUserRole("sezgin", "mod", "moderator")
+	service.SaveBetFor("mod", "tarik", 250)
+	log, err := service.GetAuditLog("mod", 1)
+	out := rendered(t, service, log)
+	if err != nil || !strings.Contains(out, "savefor") || strings.Contains(out, "\tsave\t") {
+		t.Fatal("audit log should keep only the entries of moderators", err, out)
+	}
+	for _, user := range []string{"omer", "tarik", "ali"} {
+		if strings.Contains(out, user) {
+			t.Fatal("participants are exposed in the audit log", user, out)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+// Package chart renders bets as SVG charts: the guesses of a bet as a histogram and the winner scores
+// of bets over time against their median guesses.
+package chart
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/mtyurt/slackbet"
+	"github.com/mtyurt/slackbet/format"
+)
+
+const (
+	width  = 640
+	height = 320
+	left   = 56
+	right  = 24
+	top    = 40
+	bottom = 48
+	// maxBins is the most bars a histogram has, guesses are grouped into ranges of equal width.
+	maxBins = 20
+	// maxLabels is the most labels on the x axis.
+	maxLabels = 8
+)
+
+const (
+	barColor    = "#1264a3"
+	winnerColor = "#e01e5a"
+	medianColor = "#2eb67d"
+)
+
+// plot is the area of the chart inside the axes.
+var plot = struct{ x, y, w, h float64 }{left, top, width - left - right, height - top - bottom}
+
+// Histogram renders the guesses of a closed bet, the winner score is marked with a line if it is saved.
+func Histogram(bet *slackbet.BetInfo) []byte {
+	c := newCanvas("Guesses of bet #" + strconv.Itoa(bet.ID))
+	if len(bet.Entries) == 0 {
+		c.message("No guesses")
+		return c.bytes()
+	}
+	lo, hi := bet.Entries[0].Number, bet.Entries[0].Number
+	for _, entry := range bet.Entries {
+		lo, hi = min(lo, entry.Number), max(hi, entry.Number)
+	}
+	if bet.WinnerScore != -1 {
+		lo, hi = min(lo, bet.WinnerScore), max(hi, bet.WinnerScore)
+	}
+	bins := min(maxBins, int(math.Ceil(math.Sqrt(float64(len(bet.Entries))))))
+	binWidth := (hi-lo)/bins + 1
+	bins = (hi-lo)/binWidth + 1
+	counts := make([]int, bins)
+	maxCount := 0
+	for _, entry := range bet.Entries {
+		i := (entry.Number - lo) / binWidth
+		counts[i]++
+		maxCount = max(maxCount, counts[i])
+	}
+
+	c.axes()
+	c.text(plot.x-8, plot.y+4, "end", "", strconv.Itoa(maxCount))
+	c.text(plot.x-8, plot.y+plot.h+4, "end", "", "0")
+	barWidth := plot.w / float64(bins)
+	every := (bins + maxLabels - 1) / maxLabels
+	for i, count := range counts {
+		x := plot.x + float64(i)*barWidth
+		h := float64(count) / float64(maxCount) * plot.h
+		if count > 0 {
+			fmt.Fprintf(&c.buf, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"/>`+"\n", x+1, plot.y+plot.h-h, barWidth-2, h, barColor)
+			c.text(x+barWidth/2, plot.y+plot.h-h-4, "middle", "", strconv.Itoa(count))
+		}
+		if i%every == 0 {
+			label := strconv.Itoa(lo + i*binWidth)
+			if binWidth > 1 {
+				label += "-" + strconv.Itoa(lo+(i+1)*binWidth-1)
+			}
+			c.text(x+barWidth/2, plot.y+plot.h+18, "middle", "", label)
+		}
+	}
+	if bet.WinnerScore != -1 {
+		x := plot.x + (float64(bet.WinnerScore-lo)+0.5)/float64(bins*binWidth)*plot.w
+		fmt.Fprintf(&c.buf, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s" stroke-width="2"/>`+"\n", x, plot.y-8, x, plot.y+plot.h, winnerColor)
+		c.text(x, plot.y-12, "middle", winnerColor, "winner score "+strconv.Itoa(bet.WinnerScore))
+	}
+	return c.bytes()
+}
+
+// Trend renders the winner score and the median guess of the bets in history, dates are formatted by f.
+// Bets without a winner score only have a median guess.
+func Trend(history *slackbet.History, f *format.Formatter) []byte {
+	c := newCanvas("Winner score and median guess")
+	if len(history.Bets) == 0 {
+		c.message("No closed bets")
+		return c.bytes()
+	}
+	lo, hi := history.Bets[0].MedianGuess, history.Bets[0].MedianGuess
+	for _, point := range history.Bets {
+		lo, hi = math.Min(lo, point.MedianGuess), math.Max(hi, point.MedianGuess)
+		if point.WinnerScore != -1 {
+			lo, hi = math.Min(lo, float64(point.WinnerScore)), math.Max(hi, float64(point.WinnerScore))
+		}
+	}
+	if lo == hi {
+		lo, hi = lo-1, hi+1
+	}
+	xOf := func(i int) float64 {
+		return plot.x + (float64(i)+0.5)/float64(len(history.Bets))*plot.w
+	}
+	yOf := func(v float64) float64 {
+		return plot.y + (hi-v)/(hi-lo)*plot.h
+	}
+
+	c.axes()
+	c.text(plot.x-8, plot.y+4, "end", "", formatNumber(hi))
+	c.text(plot.x-8, plot.y+plot.h+4, "end", "", formatNumber(lo))
+	every := (len(history.Bets) + maxLabels - 1) / maxLabels
+	var medians, winners bytes.Buffer
+	for i, point := range history.Bets {
+		fmt.Fprintf(&medians, "%.1f,%.1f ", xOf(i), yOf(point.MedianGuess))
+		c.point(xOf(i), yOf(point.MedianGuess), medianColor)
+		if point.WinnerScore != -1 {
+			fmt.Fprintf(&winners, "%.1f,%.1f ", xOf(i), yOf(float64(point.WinnerScore)))
+			c.point(xOf(i), yOf(float64(point.WinnerScore)), winnerColor)
+		}
+		if i%every == 0 {
+			c.text(xOf(i), plot.y+plot.h+18, "middle", "", f.Date(point.EndDate))
+		}
+	}
+	c.polyline(medians.String(), medianColor)
+	c.polyline(winners.String(), winnerColor)
+	c.text(width-right, 16, "end", winnerColor, "winner score")
+	c.text(width-right, 30, "end", medianColor, "median guess")
+	return c.bytes()
+}
+
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// canvas writes the elements of an SVG document.
+type canvas struct {
+	buf bytes.Buffer
+}
+
+func newCanvas(title string) *canvas {
+	c := &canvas{}
+	fmt.Fprintf(&c.buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="Helvetica, Arial, sans-serif" font-size="12">`+"\n",
+		width, height, width, height)
+	fmt.Fprintf(&c.buf, `<rect width="%d" height="%d" fill="#fff"/>`+"\n", width, height)
+	c.buf.WriteString(`<text x="16" y="24" font-size="16" font-weight="bold">`)
+	xml.EscapeText(&c.buf, []byte(title))
+	c.buf.WriteString("</text>\n")
+	return c
+}
+
+func (c *canvas) axes() {
+	fmt.Fprintf(&c.buf, `<path d="M%.1f %.1fV%.1fH%.1f" fill="none" stroke="#616061"/>`+"\n", plot.x, plot.y, plot.y+plot.h, plot.x+plot.w)
+}
+
+// text writes s at x, y, anchor is start, middle or end and fill is black if it is empty.
+func (c *canvas) text(x float64, y float64, anchor string, fill string, s string) {
+	if fill == "" {
+		fill = "#1d1c1d"
+	}
+	fmt.Fprintf(&c.buf, `<text x="%.1f" y="%.1f" text-anchor="%s" fill="%s">`, x, y, anchor, fill)
+	xml.EscapeText(&c.buf, []byte(s))
+	c.buf.WriteString("</text>\n")
+}
+
+func (c *canvas) message(s string) {
+	c.text(width/2, height/2, "middle", "#616061", s)
+}
+
+func (c *canvas) point(x float64, y float64, color string) {
+	fmt.Fprintf(&c.buf, `<circle cx="%.1f" cy="%.1f" r="3" fill="%s"/>`+"\n", x, y, color)
+}
+
+func (c *canvas) polyline(points string, color string) {
+	if points == "" {
+		return
+	}
+	fmt.Fprintf(&c.buf, `<polyline points="%s" fill="none" stroke="%s" stroke-width="2"/>`+"\n", points, color)
+}
+
+func (c *canvas) bytes() []byte {
+	c.buf.WriteString("</svg>\n")
+	return c.buf.Bytes()
+}
@@ -0,0 +1,67 @@
+package chart
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mtyurt/slackbet"
+	"github.com/mtyurt/slackbet/format"
+)
+
+// checkSVG fails if svg is not well-formed XML.
+func checkSVG(t *testing.T, svg []byte) {
+	decoder := xml.NewDecoder(strings.NewReader(string(svg)))
+	for {
+		_, err := decoder.Token()
+		if err != nil {
+			if err.Error() != "EOF" {
+				t.Fatal("svg is not valid", err, string(svg))
+			}
+			return
+		}
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	bet := &slackbet.BetInfo{ID: 3, Status: "closed", WinnerScore: 120, Entries: []slackbet.Entry{
+		{User: "tarik", Number: 75}, {User: "omer", Number: 100, Winner: true}, {User: "sezgin", Number: 110, Winner: true}, {User: "ali", Number: 250}}}
+	svg := Histogram(bet)
+	checkSVG(t, svg)
+	s := string(svg)
+	if !strings.Contains(s, "Guesses of bet #3") || !strings.Contains(s, "winner score 120") || strings.Count(s, "<rect") != 3 {
+		t.Fatal("histogram is wrong", s)
+	}
+	// 4 guesses are grouped into 2 bins of 88 between 75 and 250
+	if !strings.Contains(s, ">75-162</text>") || !strings.Contains(s, ">163-250</text>") || !strings.Contains(s, `fill="#1d1c1d">3</text>`) {
+		t.Fatal("bins are wrong", s)
+	}
+	bet.WinnerScore = -1
+	if s = string(Histogram(bet)); strings.Contains(s, "winner score") {
+		t.Fatal("winner score should not be marked", s)
+	}
+	svg = Histogram(&slackbet.BetInfo{ID: 4, Entries: []slackbet.Entry{}})
+	checkSVG(t, svg)
+	if !strings.Contains(string(svg), "No guesses") {
+		t.Fatal("empty histogram is wrong", string(svg))
+	}
+}
+
+func TestTrend(t *testing.T) {
+	f := &format.Formatter{Layout: "2006-01", Location: time.UTC}
+	history := &slackbet.History{Bets: []slackbet.HistoryPoint{
+		{ID: 1, EndDate: time.Date(2016, 1, 31, 0, 0, 0, 0, time.UTC), WinnerScore: 100, Guesses: 3, MedianGuess: 90},
+		{ID: 2, EndDate: time.Date(2016, 2, 29, 0, 0, 0, 0, time.UTC), WinnerScore: -1, Guesses: 2, MedianGuess: 150.5},
+		{ID: 3, EndDate: time.Date(2016, 3, 31, 0, 0, 0, 0, time.UTC), WinnerScore: 200, Guesses: 4, MedianGuess: 180},
+	}}
+	svg := Trend(history, f)
+	checkSVG(t, svg)
+	s := string(svg)
+	if strings.Count(s, "<polyline") != 2 || strings.Count(s, "<circle") != 5 || !strings.Contains(s, ">2016-02</text>") || !strings.Contains(s, ">200</text>") {
+		t.Fatal("trend is wrong", s)
+	}
+	if s = string(Trend(&slackbet.History{}, f)); !strings.Contains(s, "No closed bets") {
+		t.Fatal("empty trend is wrong", s)
+	}
+}
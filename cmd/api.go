@@ -0,0 +1,331 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mtyurt/slackbet"
+	"github.com/mtyurt/slackbet/bet"
+	"github.com/mtyurt/slackbet/format"
+)
+
+type apiWinner struct {
+	Score   *int     `json:"score"`
+	Winners []string `json:"winners"`
+}
+
+type apiErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// errHiddenEntries is returned for the entries of an open bet.
+var errHiddenEntries = errors.New("Guesses are hidden until the bet ends.")
+
+// apiHandler serves the JSON API under /api/v1/. Requests are authenticated with a key of Conf.APIKeys,
+// given as "Authorization: Bearer <key>" or "X-API-Key: <key>", and commands run as the user of the key.
+func apiHandler(current func() *bet.BetService, logger *slog.Logger) http.Handler {
+	mux := http.NewServeMux()
+	handle := func(pattern string, handler func(*bet.BetService, string, *http.Request) (int, interface{}, error)) {
+		mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			requestID := r.Header.Get("X-Request-Id")
+			if requestID == "" {
+				requestID = newRequestID()
+			}
+			w.Header().Set("X-Request-Id", requestID)
+			service := current()
+			user, ok := apiUser(service.Conf, r)
+			if !ok {
+				writeJSON(w, http.StatusUnauthorized, apiErrorResponse{"A valid API key is required."})
+				logger.Info("api request rejected", "requestId", requestID, "method", r.Method, "path", r.URL.Path)
+				return
+			}
+			requestLogger := logger.With("requestId", requestID, "user", user, "method", r.Method, "path", r.URL.Path)
+			requestService := *service
+			requestService.Logger = requestLogger
+			status, response, err := handler(&requestService, user, r)
+			if err != nil {
+				status = apiErrorStatus(err)
+				response = apiErrorResponse{err.Error()}
+				requestLogger.Info("api request failed", "err", err)
+			}
+			writeJSON(w, status, response)
+			requestLogger.Info("api request handled", "status", status, "duration", time.Since(start))
+		})
+	}
+	handle("GET /api/v1/bets", listBetsAPI)
+	handle("POST /api/v1/bets", startBetAPI)
+	handle("GET /api/v1/bets/{id}", getBetAPI)
+	handle("PATCH /api/v1/bets/{id}", updateBetAPI)
+	handle("GET /api/v1/bets/{id}/entries", getEntriesAPI)
+	handle("POST /api/v1/bets/{id}/entries", saveEntryAPI)
+	handle("GET /api/v1/bets/{id}/winner", getWinnerAPI)
+	handle("PUT /api/v1/bets/{id}/winner", saveWinnerAPI)
+	handle("DELETE /api/v1/bets/{id}/winner", clearWinnerAPI)
+	return mux
+}
+
+// apiUser returns the user of the API key of the request.
+func apiUser(conf *slackbet.Conf, r *http.Request) (string, bool) {
+	key := r.Header.Get("X-API-Key")
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		key = strings.TrimPrefix(auth, "Bearer ")
+	}
+	if key == "" {
+		return "", false
+	}
+	user := ""
+	for k, u := range conf.APIKeys {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			user = u
+		}
+	}
+	return user, user != ""
+}
+
+// apiErrorStatus maps errors of BetService to HTTP statuses, errors that are not recognized are bad requests.
+func apiErrorStatus(err error) int {
+	var requestErr *apiRequestError
+	switch {
+	case errors.As(err, &requestErr):
+		return requestErr.status
+	case err == errHiddenEntries || strings.HasPrefix(err.Error(), "You are not authorized"):
+		return http.StatusForbidden
+	case err.Error() == "No such bet exists.":
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
+
+// apiRequestError is an error of a request with its HTTP status.
+type apiRequestError struct {
+	status  int
+	message string
+}
+
+func (err *apiRequestError) Error() string {
+	return err.message
+}
+
+func writeJSON(w http.ResponseWriter, status int, response interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+func listBetsAPI(service *bet.BetService, user string, r *http.Request) (int, interface{}, error) {
+	query := slackbet.ListQuery{Status: r.URL.Query().Get("status")}
+	for name, value := range map[string]*int{"count": &query.Count, "page": &query.Page, "year": &query.Year} {
+		if s := r.URL.Query().Get(name); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				return 0, nil, errors.New(name + " is not a valid integer " + s)
+			}
+			*value = n
+		}
+	}
+	if query.Status != "" && query.Status != "open" && query.Status != "closed" {
+		return 0, nil, errors.New("status should be open or closed.")
+	}
+	query.HasWinner = r.URL.Query().Get("hasWinner") == "true"
+	list, err := service.ListBets(query)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, format.New(service.Conf).JSON(list), nil
+}
+
+func startBetAPI(service *bet.BetService, user string, r *http.Request) (int, interface{}, error) {
+	_, err := service.StartNewBet(user)
+	if err != nil {
+		return 0, nil, err
+	}
+	betID, err := service.Repo.GetIDOfOpenBet()
+	if err != nil {
+		return 0, nil, err
+	}
+	return betResponse(service, betID, http.StatusCreated)
+}
+
+func getBetAPI(service *bet.BetService, user string, r *http.Request) (int, interface{}, error) {
+	betID, err := pathBetID(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	return betResponse(service, betID, http.StatusOK)
+}
+
+// updateBetAPI ends or reopens a bet, the body is {"status": "closed"} or {"status": "open"}.
+func updateBetAPI(service *bet.BetService, user string, r *http.Request) (int, interface{}, error) {
+	betID, err := pathBetID(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	var body struct {
+		Status string `json:"status"`
+	}
+	if err = decodeBody(r, &body); err != nil {
+		return 0, nil, err
+	}
+	switch body.Status {
+	case "closed":
+		if err = checkOpenBet(service, betID); err != nil {
+			return 0, nil, err
+		}
+		_, err = service.EndBet(user)
+	case "open":
+		_, err = service.ReopenBet(user, betID)
+	default:
+		return 0, nil, errors.New("status should be open or closed.")
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	return betResponse(service, betID, http.StatusOK)
+}
+
+func getEntriesAPI(service *bet.BetService, user string, r *http.Request) (int, interface{}, error) {
+	betID, err := pathBetID(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	info, err := getBetInfo(service, betID)
+	if err != nil {
+		return 0, nil, err
+	}
+	if info.Entries == nil {
+		return 0, nil, errHiddenEntries
+	}
+	return http.StatusOK, format.BetJSON(info).Entries, nil
+}
+
+// saveEntryAPI saves a guess in the open bet, the body is {"user": "omer", "number": 100}.
+// The user is the user of the API key if it is empty, the salt of a guess in a sealed bet is in the response.
+func saveEntryAPI(service *bet.BetService, user string, r *http.Request) (int, interface{}, error) {
+	betID, err := pathBetID(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	var entry format.Entry
+	if err = decodeBody(r, &entry); err != nil {
+		return 0, nil, err
+	}
+	if err = checkOpenBet(service, betID); err != nil {
+		return 0, nil, err
+	}
+	var confirmation *slackbet.Confirmation
+	if entry.User == "" || entry.User == user {
+		entry.User = user
+		confirmation, err = service.SaveBet(user, entry.Number, entry.ExtraInfo)
+	} else {
+		confirmation, err = service.SaveBetFor(user, entry.User, entry.Number)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	entry.Salt = confirmation.Salt
+	return http.StatusCreated, entry, nil
+}
+
+func getWinnerAPI(service *bet.BetService, user string, r *http.Request) (int, interface{}, error) {
+	betID, err := pathBetID(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	info, err := getBetInfo(service, betID)
+	if err != nil {
+		return 0, nil, err
+	}
+	response := apiWinner{Score: format.BetJSON(info).WinnerScore, Winners: []string{}}
+	for _, entry := range info.Entries {
+		if entry.Winner {
+			response.Winners = append(response.Winners, entry.User)
+		}
+	}
+	return http.StatusOK, response, nil
+}
+
+// saveWinnerAPI saves the winner score of a bet, the body is {"score": 4815}.
+func saveWinnerAPI(service *bet.BetService, user string, r *http.Request) (int, interface{}, error) {
+	betID, err := pathBetID(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	var body struct {
+		Score *int `json:"score"`
+	}
+	if err = decodeBody(r, &body); err != nil {
+		return 0, nil, err
+	}
+	if body.Score == nil {
+		return 0, nil, errors.New("score is required.")
+	}
+	if _, err = service.SaveWinner(user, betID, *body.Score); err != nil {
+		return 0, nil, err
+	}
+	return getWinnerAPI(service, user, r)
+}
+
+func clearWinnerAPI(service *bet.BetService, user string, r *http.Request) (int, interface{}, error) {
+	betID, err := pathBetID(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	if _, err = service.ClearWinner(user, betID); err != nil {
+		return 0, nil, err
+	}
+	return getWinnerAPI(service, user, r)
+}
+
+func betResponse(service *bet.BetService, betID int, status int) (int, interface{}, error) {
+	info, err := getBetInfo(service, betID)
+	if err != nil {
+		return 0, nil, err
+	}
+	return status, format.BetJSON(info), nil
+}
+
+// getBetInfo returns the bet with betID, GetBetInfo would return the latest bet for -1.
+func getBetInfo(service *bet.BetService, betID int) (*slackbet.BetInfo, error) {
+	if betID < 1 {
+		return nil, errors.New("No such bet exists.")
+	}
+	return service.GetBetInfo(betID)
+}
+
+// checkOpenBet returns a conflict if betID is not the open bet, guesses are only saved in the open bet.
+func checkOpenBet(service *bet.BetService, betID int) error {
+	openBetID, err := service.Repo.GetIDOfOpenBet()
+	if err != nil {
+		return err
+	}
+	if openBetID != betID {
+		if _, err = getBetInfo(service, betID); err != nil {
+			return err
+		}
+		return &apiRequestError{http.StatusConflict, "Bet " + strconv.Itoa(betID) + " is not open."}
+	}
+	return nil
+}
+
+func pathBetID(r *http.Request) (int, error) {
+	betID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return 0, errors.New("betID is not a valid integer " + r.PathValue("id"))
+	}
+	return betID, nil
+}
+
+func decodeBody(r *http.Request, body interface{}) error {
+	err := json.NewDecoder(r.Body).Decode(body)
+	if err != nil {
+		return errors.New("body is not valid JSON: " + err.Error())
+	}
+	return nil
+}
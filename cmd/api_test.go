@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mtyurt/slackbet/bet"
+	"github.com/mtyurt/slackbet/format"
+)
+
+const (
+	adminKey  = "admin-key-0123456789"
+	playerKey = "player-key-0123456789"
+)
+
+func apiRequest(t *testing.T, handler http.Handler, key string, method string, path string, body string) (int, string) {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	if recorder.Header().Get("Content-Type") != "application/json" {
+		t.Fatal("response should be JSON", recorder.Header())
+	}
+	return recorder.Code, recorder.Body.String()
+}
+
+func TestAPI(t *testing.T) {
+	service := mockService()
+	service.Conf.APIKeys = map[string]string{adminKey: "sezgin", playerKey: "omer"}
+	cli, err := openRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli.Cmd("FLUSHALL")
+	handler := apiHandler(func() *bet.BetService { return service }, slog.Default())
+
+	if code, _ := apiRequest(t, handler, "", "GET", "/api/v1/bets", ""); code != http.StatusUnauthorized {
+		t.Fatal("request without a key should be rejected", code)
+	}
+	if code, _ := apiRequest(t, handler, "wrong-key-0123456789", "GET", "/api/v1/bets", ""); code != http.StatusUnauthorized {
+		t.Fatal("request with a wrong key should be rejected", code)
+	}
+	if code, body := apiRequest(t, handler, playerKey, "POST", "/api/v1/bets", ""); code != http.StatusForbidden {
+		t.Fatal("player should not start a bet", code, body)
+	}
+	code, body := apiRequest(t, handler, adminKey, "POST", "/api/v1/bets", "")
+	var b format.Bet
+	if err := json.Unmarshal([]byte(body), &b); err != nil || code != http.StatusCreated || b.ID != 1 || b.Status != "open" {
+		t.Fatal("bet should be started", code, body)
+	}
+	if code, body = apiRequest(t, handler, playerKey, "POST", "/api/v1/bets/1/entries", `{"number": 100}`); code != http.StatusCreated || !strings.Contains(body, `"user":"omer"`) {
+		t.Fatal("entry should be saved", code, body)
+	}
+	if code, body = apiRequest(t, handler, adminKey, "POST", "/api/v1/bets/1/entries", `{"user": "tarik", "number": 250}`); code != http.StatusCreated {
+		t.Fatal("entry should be saved for tarik", code, body)
+	}
+	if code, body = apiRequest(t, handler, playerKey, "GET", "/api/v1/bets/1/entries", ""); code != http.StatusForbidden {
+		t.Fatal("entries of open bet should be hidden", code, body)
+	}
+	if code, body = apiRequest(t, handler, playerKey, "GET", "/api/v1/bets/1", ""); code != http.StatusOK || strings.Contains(body, "100") || !strings.Contains(body, `"entryCount":2`) {
+		t.Fatal("open bet should not show guesses", code, body)
+	}
+	if code, body = apiRequest(t, handler, adminKey, "PATCH", "/api/v1/bets/1", `{"status": "closed"}`); code != http.StatusOK || !strings.Contains(body, `"status":"closed"`) {
+		t.Fatal("bet should be ended", code, body)
+	}
+	if code, body = apiRequest(t, handler, adminKey, "POST", "/api/v1/bets/1/entries", `{"number": 1}`); code != http.StatusConflict {
+		t.Fatal("entries should only be saved in the open bet", code, body)
+	}
+	if code, body = apiRequest(t, handler, adminKey, "PUT", "/api/v1/bets/1/winner", `{"score": 120}`); code != http.StatusOK || body != "{\"score\":120,\"winners\":[\"omer\"]}\n" {
+		t.Fatal("winner should be saved", code, body)
+	}
+	code, body = apiRequest(t, handler, playerKey, "GET", "/api/v1/bets/1/entries", "")
+	var entries []format.Entry
+	if err := json.Unmarshal([]byte(body), &entries); err != nil || code != http.StatusOK || len(entries) != 2 || entries[0] != (format.Entry{User: "omer", Number: 100, Winner: true}) {
+		t.Fatal("entries should be listed", code, body)
+	}
+	code, body = apiRequest(t, handler, playerKey, "GET", "/api/v1/bets?status=closed&hasWinner=true", "")
+	var page format.BetPage
+	if err := json.Unmarshal([]byte(body), &page); err != nil || code != http.StatusOK || len(page.Bets) != 1 || *page.Bets[0].WinnerScore != 120 || page.Bets[0].EntryCount != nil {
+		t.Fatal("bets should be listed", code, body)
+	}
+	if code, body = apiRequest(t, handler, playerKey, "GET", "/api/v1/bets/7", ""); code != http.StatusNotFound {
+		t.Fatal("bet should not exist", code, body)
+	}
+	if code, body = apiRequest(t, handler, playerKey, "GET", "/api/v1/bets?page=x", ""); code != http.StatusBadRequest {
+		t.Fatal("page should be invalid", code, body)
+	}
+}
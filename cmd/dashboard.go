@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mtyurt/slackbet"
+	"github.com/mtyurt/slackbet/bet"
+	"github.com/mtyurt/slackbet/chart"
+	"github.com/mtyurt/slackbet/format"
+)
+
+// dashboardCookie keeps the session of the dashboard, its value is derived from Conf.DashboardSecret
+// so changing the secret logs everyone out.
+const dashboardCookie = "slackbet_dashboard"
+
+// dashboardPageCount is the number of bets on a page of the dashboard.
+const dashboardPageCount = 20
+
+//go:embed dashboard.html
+var dashboardTemplates string
+
+var dashboardTemplate = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"inc": func(i int) int { return i + 1 },
+	"dec": func(i int) int { return i - 1 },
+}).Parse(dashboardTemplates))
+
+// dashboardPage is the data of a dashboard template, only the fields of the page are set.
+type dashboardPage struct {
+	Title       string
+	LoggedIn    bool
+	Format      *format.Formatter
+	Error       string
+	Year        int
+	List        *slackbet.BetList
+	Bet         *slackbet.BetInfo
+	Leaderboard *slackbet.Leaderboard
+}
+
+// dashboardHandler serves the web dashboard under /dashboard/, it is not found if Conf.DashboardSecret is empty.
+// Pages need a session cookie that is given by logging in with the secret. Guesses of a bet are shown after it ends.
+func dashboardHandler(current func() *bet.BetService, logger *slog.Logger) http.Handler {
+	mux := http.NewServeMux()
+	authorized := func(w http.ResponseWriter, r *http.Request) (*bet.BetService, bool) {
+		service := current()
+		if service.Conf.DashboardSecret == "" {
+			http.NotFound(w, r)
+			return nil, false
+		}
+		if !dashboardLoggedIn(service.Conf, r) {
+			http.Redirect(w, r, "/dashboard/login", http.StatusSeeOther)
+			return nil, false
+		}
+		return service, true
+	}
+	handle := func(pattern string, page func(*bet.BetService, *http.Request) (string, *dashboardPage, error)) {
+		mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			service, ok := authorized(w, r)
+			if !ok {
+				return
+			}
+			name, data, err := page(service, r)
+			status := http.StatusOK
+			if err != nil {
+				status = apiErrorStatus(err)
+				name, data = "error", &dashboardPage{Title: "Error", Error: err.Error()}
+				logger.Info("dashboard request failed", "path", r.URL.Path, "err", err)
+			}
+			data.LoggedIn = true
+			data.Format = format.New(service.Conf)
+			renderDashboard(w, logger, status, name, data)
+			logger.Info("dashboard request handled", "path", r.URL.Path, "status", status, "duration", time.Since(start))
+		})
+	}
+	handleSVG := func(pattern string, render func(*bet.BetService, *http.Request) ([]byte, error)) {
+		mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+			service, ok := authorized(w, r)
+			if !ok {
+				return
+			}
+			svg, err := render(service, r)
+			if err != nil {
+				logger.Info("dashboard chart failed", "path", r.URL.Path, "err", err)
+				http.Error(w, err.Error(), apiErrorStatus(err))
+				return
+			}
+			w.Header().Set("Content-Type", "image/svg+xml")
+			w.Header().Set("Content-Security-Policy", "default-src 'none'")
+			w.Write(svg)
+		})
+	}
+	handle("GET /dashboard/{$}", listPage)
+	handle("GET /dashboard/bets/{id}", betPage)
+	handle("GET /dashboard/leaderboard", leaderboardPage)
+	handle("GET /dashboard/trends", trendsPage)
+	handleSVG("GET /dashboard/bets/{id}/histogram.svg", histogramSVG)
+	handleSVG("GET /dashboard/trend.svg", trendSVG)
+	mux.HandleFunc("GET /dashboard/login", func(w http.ResponseWriter, r *http.Request) {
+		if current().Conf.DashboardSecret == "" {
+			http.NotFound(w, r)
+			return
+		}
+		renderDashboard(w, logger, http.StatusOK, "login", &dashboardPage{Title: "Log in"})
+	})
+	mux.HandleFunc("POST /dashboard/login", func(w http.ResponseWriter, r *http.Request) {
+		conf := current().Conf
+		if conf.DashboardSecret == "" {
+			http.NotFound(w, r)
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(r.PostFormValue("secret")), []byte(conf.DashboardSecret)) != 1 {
+			logger.Info("dashboard login failed", "remoteAddr", r.RemoteAddr)
+			renderDashboard(w, logger, http.StatusUnauthorized, "login", &dashboardPage{Title: "Log in", Error: "Wrong secret."})
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: dashboardCookie, Value: dashboardSession(conf.DashboardSecret), Path: "/dashboard/",
+			HttpOnly: true, Secure: r.TLS != nil, SameSite: http.SameSiteLaxMode})
+		http.Redirect(w, r, "/dashboard/", http.StatusSeeOther)
+	})
+	mux.HandleFunc("POST /dashboard/logout", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: dashboardCookie, Path: "/dashboard/", MaxAge: -1, HttpOnly: true})
+		http.Redirect(w, r, "/dashboard/login", http.StatusSeeOther)
+	})
+	return mux
+}
+
+// dashboardSession returns the session cookie value of secret.
+func dashboardSession(secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("slackbet dashboard"))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func dashboardLoggedIn(conf *slackbet.Conf, r *http.Request) bool {
+	cookie, err := r.Cookie(dashboardCookie)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal([]byte(cookie.Value), []byte(dashboardSession(conf.DashboardSecret)))
+}
+
+// renderDashboard renders the template to a buffer first, so that a failed template is a server error.
+func renderDashboard(w http.ResponseWriter, logger *slog.Logger, status int, name string, data *dashboardPage) {
+	var buf bytes.Buffer
+	if err := dashboardTemplate.ExecuteTemplate(&buf, name, data); err != nil {
+		logger.Error("dashboard template failed", "template", name, "err", err)
+		http.Error(w, "dashboard cannot be rendered", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; img-src 'self'; form-action 'self'; frame-ancestors 'none'")
+	w.WriteHeader(status)
+	buf.WriteTo(w)
+}
+
+// listPage lists visible bets, newest first, the query is ?page=<n>&year=<yyyy>.
+func listPage(service *bet.BetService, r *http.Request) (string, *dashboardPage, error) {
+	query := slackbet.ListQuery{Count: dashboardPageCount}
+	for name, value := range map[string]*int{"page": &query.Page, "year": &query.Year} {
+		if s := r.URL.Query().Get(name); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				return "", nil, errors.New(name + " is not a valid integer " + s)
+			}
+			*value = n
+		}
+	}
+	list, err := service.ListBets(query)
+	if err != nil {
+		return "", nil, err
+	}
+	reverseBets(list.Bets)
+	return "list", &dashboardPage{Title: "Bets", Year: query.Year, List: list}, nil
+}
+
+func betPage(service *bet.BetService, r *http.Request) (string, *dashboardPage, error) {
+	betID, err := pathBetID(r)
+	if err != nil {
+		return "", nil, err
+	}
+	info, err := getBetInfo(service, betID)
+	if err != nil {
+		return "", nil, err
+	}
+	return "bet", &dashboardPage{Title: "Bet #" + strconv.Itoa(betID), Bet: info}, nil
+}
+
+func leaderboardPage(service *bet.BetService, r *http.Request) (string, *dashboardPage, error) {
+	leaderboard, err := service.GetLeaderboard()
+	if err != nil {
+		return "", nil, err
+	}
+	return "leaderboard", &dashboardPage{Title: "Leaderboard", Leaderboard: leaderboard}, nil
+}
+
+func trendsPage(service *bet.BetService, r *http.Request) (string, *dashboardPage, error) {
+	return "trends", &dashboardPage{Title: "Trends"}, nil
+}
+
+// histogramSVG renders the guesses of a closed bet, guesses of the open bet are hidden.
+func histogramSVG(service *bet.BetService, r *http.Request) ([]byte, error) {
+	betID, err := pathBetID(r)
+	if err != nil {
+		return nil, err
+	}
+	info, err := getBetInfo(service, betID)
+	if err != nil {
+		return nil, err
+	}
+	if info.Entries == nil {
+		return nil, errHiddenEntries
+	}
+	return chart.Histogram(info), nil
+}
+
+func trendSVG(service *bet.BetService, r *http.Request) ([]byte, error) {
+	history, err := service.GetHistory()
+	if err != nil {
+		return nil, err
+	}
+	return chart.Trend(history, format.New(service.Conf)), nil
+}
+
+func reverseBets(bets []slackbet.BetInfo) {
+	for i, j := 0, len(bets)-1; i < j; i, j = i+1, j-1 {
+		bets[i], bets[j] = bets[j], bets[i]
+	}
+}
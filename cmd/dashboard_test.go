@@ -0,0 +1,114 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/mtyurt/slackbet/bet"
+)
+
+const dashboardSecret = "dashboard-secret-0123"
+
+func dashboardRequest(handler http.Handler, cookie *http.Cookie, method string, path string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestDashboard(t *testing.T) {
+	service := mockService()
+	cli, err := openRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli.Cmd("FLUSHALL")
+	handler := dashboardHandler(func() *bet.BetService { return service }, slog.Default())
+
+	if resp := dashboardRequest(handler, nil, "GET", "/dashboard/login", nil); resp.Code != http.StatusNotFound {
+		t.Fatal("dashboard should be disabled without a secret", resp.Code)
+	}
+	service.Conf.DashboardSecret = dashboardSecret
+	if resp := dashboardRequest(handler, nil, "GET", "/dashboard/", nil); resp.Code != http.StatusSeeOther || resp.Header().Get("Location") != "/dashboard/login" {
+		t.Fatal("dashboard should redirect to login", resp.Code, resp.Header())
+	}
+	forged := &http.Cookie{Name: dashboardCookie, Value: "forged"}
+	if resp := dashboardRequest(handler, forged, "GET", "/dashboard/", nil); resp.Code != http.StatusSeeOther {
+		t.Fatal("forged cookie should be rejected", resp.Code)
+	}
+	if resp := dashboardRequest(handler, nil, "POST", "/dashboard/login", url.Values{"secret": {"wrong"}}); resp.Code != http.StatusUnauthorized || !strings.Contains(resp.Body.String(), "Wrong secret.") {
+		t.Fatal("wrong secret should be rejected", resp.Code, resp.Body)
+	}
+	resp := dashboardRequest(handler, nil, "POST", "/dashboard/login", url.Values{"secret": {dashboardSecret}})
+	cookies := resp.Result().Cookies()
+	if resp.Code != http.StatusSeeOther || len(cookies) != 1 || !cookies[0].HttpOnly {
+		t.Fatal("login failed", resp.Code, cookies)
+	}
+	session := cookies[0]
+
+	if resp = dashboardRequest(handler, session, "GET", "/dashboard/", nil); resp.Code != http.StatusOK || !strings.Contains(resp.Body.String(), "There are no bets yet.") {
+		t.Fatal("empty list is wrong", resp.Code, resp.Body)
+	}
+	service.StartNewBet("sezgin")
+	service.SaveBet("omer", 100, "")
+	service.SaveBet("tarik", 250, "<script>")
+	if resp = dashboardRequest(handler, session, "GET", "/dashboard/bets/1", nil); resp.Code != http.StatusOK || strings.Contains(resp.Body.String(), "250") ||
+		!strings.Contains(resp.Body.String(), "2 guesses so far") {
+		t.Fatal("guesses of open bet should be hidden", resp.Code, resp.Body)
+	}
+	if resp = dashboardRequest(handler, session, "GET", "/dashboard/bets/1/histogram.svg", nil); resp.Code != http.StatusForbidden {
+		t.Fatal("histogram of open bet should be hidden", resp.Code, resp.Body)
+	}
+	service.EndBet("sezgin")
+	service.SaveWinner("sezgin", 1, 120)
+	service.WaitCallbacks(t.Context())
+
+	resp = dashboardRequest(handler, session, "GET", "/dashboard/bets/1", nil)
+	body := resp.Body.String()
+	if resp.Code != http.StatusOK || !strings.Contains(body, "Winner score is <strong>120</strong>") || !strings.Contains(body, "&lt;script&gt;") || strings.Contains(body, "<script>") {
+		t.Fatal("closed bet is wrong", resp.Code, body)
+	}
+	if !strings.Contains(body, "<tr class=\"winner\">\n<td>1.</td>\n<td>omer &#127942;</td>") {
+		t.Fatal("winner should be highlighted", body)
+	}
+	if !strings.Contains(body, `<img src="/dashboard/bets/1/histogram.svg"`) {
+		t.Fatal("histogram should be shown", body)
+	}
+	for _, path := range []string{"/dashboard/bets/1/histogram.svg", "/dashboard/trend.svg"} {
+		if resp = dashboardRequest(handler, session, "GET", path, nil); resp.Code != http.StatusOK || resp.Header().Get("Content-Type") != "image/svg+xml" ||
+			!strings.HasPrefix(resp.Body.String(), "<svg") {
+			t.Fatal("chart is wrong", path, resp.Code, resp.Body)
+		}
+	}
+	if resp = dashboardRequest(handler, nil, "GET", "/dashboard/trend.svg", nil); resp.Code != http.StatusSeeOther {
+		t.Fatal("chart should need a session", resp.Code)
+	}
+	if resp = dashboardRequest(handler, session, "GET", "/dashboard/", nil); resp.Code != http.StatusOK || !strings.Contains(resp.Body.String(), `<a href="/dashboard/bets/1">#1</a>`) {
+		t.Fatal("list is wrong", resp.Code, resp.Body)
+	}
+	if resp = dashboardRequest(handler, session, "GET", "/dashboard/leaderboard", nil); resp.Code != http.StatusOK ||
+		!strings.Contains(resp.Body.String(), "<td>omer</td>\n<td class=\"number\">1</td>") {
+		t.Fatal("leaderboard is wrong", resp.Code, resp.Body)
+	}
+	if resp = dashboardRequest(handler, session, "GET", "/dashboard/bets/7", nil); resp.Code != http.StatusNotFound {
+		t.Fatal("missing bet should not be found", resp.Code)
+	}
+	if resp = dashboardRequest(handler, session, "GET", "/dashboard/?page=x", nil); resp.Code != http.StatusBadRequest {
+		t.Fatal("invalid page should be rejected", resp.Code)
+	}
+
+	service.Conf.DashboardSecret = "another-secret-0123"
+	if resp = dashboardRequest(handler, session, "GET", "/dashboard/", nil); resp.Code != http.StatusSeeOther {
+		t.Fatal("session of old secret should be rejected", resp.Code)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// readinessTimeout bounds every readiness check, a check that takes longer is failed.
+const readinessTimeout = 3 * time.Second
+
+var errCheckTimeout = errors.New("check timed out after " + readinessTimeout.String())
+
+// dependency is a readiness check of something the bot needs to serve commands.
+type dependency struct {
+	Name  string
+	Check func() error
+}
+
+type dependencyStatus struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type healthResponse struct {
+	Status       string                      `json:"status"`
+	Dependencies map[string]dependencyStatus `json:"dependencies,omitempty"`
+}
+
+// healthHandler reports that the process is up, it doesn't check dependencies.
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusOK, healthResponse{Status: "ok"})
+}
+
+// readyHandler checks dependencies concurrently and responds 503 if any of them fails.
+func readyHandler(dependencies []dependency) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		results := make([]chan error, len(dependencies))
+		for i, dep := range dependencies {
+			results[i] = make(chan error, 1)
+			go func(check func() error, result chan error) {
+				result <- check()
+			}(dep.Check, results[i])
+		}
+		response := healthResponse{Status: "ok", Dependencies: make(map[string]dependencyStatus)}
+		status := http.StatusOK
+		ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+		defer cancel()
+		for i, dep := range dependencies {
+			var err error
+			select {
+			case err = <-results[i]:
+			case <-ctx.Done():
+				err = errCheckTimeout
+			}
+			if err != nil {
+				response.Dependencies[dep.Name] = dependencyStatus{Status: "error", Error: err.Error()}
+				response.Status = "error"
+				status = http.StatusServiceUnavailable
+			} else {
+				response.Dependencies[dep.Name] = dependencyStatus{Status: "ok"}
+			}
+		}
+		writeHealth(w, status, response)
+	}
+}
+
+func writeHealth(w http.ResponseWriter, status int, response healthResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
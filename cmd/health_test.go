@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReadyHandler(t *testing.T) {
+	service := mockService()
+	slackCheck := func() error { return nil }
+	handler := readyHandler([]dependency{
+		{Name: "redis", Check: service.Repo.Ping},
+		{Name: "slack", Check: func() error { return slackCheck() }},
+	})
+
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest("GET", "/readyz", nil))
+	var response healthResponse
+	json.NewDecoder(recorder.Body).Decode(&response)
+	if recorder.Code != http.StatusOK || response.Status != "ok" || response.Dependencies["redis"].Status != "ok" || response.Dependencies["slack"].Status != "ok" {
+		t.Fatal("should be ready", recorder.Code, response)
+	}
+
+	slackCheck = func() error { return errors.New("slack returned invalid_auth") }
+	recorder = httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest("GET", "/readyz", nil))
+	response = healthResponse{}
+	json.NewDecoder(recorder.Body).Decode(&response)
+	if recorder.Code != http.StatusServiceUnavailable || response.Status != "error" || response.Dependencies["redis"].Status != "ok" ||
+		response.Dependencies["slack"] != (dependencyStatus{Status: "error", Error: "slack returned invalid_auth"}) {
+		t.Fatal("should not be ready", recorder.Code, response)
+	}
+
+	recorder = httptest.NewRecorder()
+	healthHandler(recorder, httptest.NewRequest("GET", "/healthz", nil))
+	if recorder.Code != http.StatusOK || recorder.Body.String() != "{\"status\":\"ok\"}\n" {
+		t.Fatal("should be healthy", recorder.Code, recorder.Body.String())
+	}
+}
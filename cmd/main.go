@@ -1,40 +1,59 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
+	_ "time/tzdata"
 
 	"github.com/mtyurt/slackbet"
 	"github.com/mtyurt/slackbet/bet"
+	"github.com/mtyurt/slackbet/format"
+	"github.com/mtyurt/slackbet/metrics"
+	"github.com/mtyurt/slackbet/outbox"
 	"github.com/mtyurt/slackbet/repo"
+	"github.com/mtyurt/slackbet/slack"
 	"github.com/mtyurt/slackcommander"
 )
 
 const availableCommands = "Available commands: save, list, info, last, whowins "
 
-func startHandler(service slackbet.BetService) func(string, []string) (string, error) {
-	return func(user string, args []string) (string, error) {
+func startHandler(service slackbet.BetService) func(string, []string) (slackbet.Result, error) {
+	return func(user string, args []string) (slackbet.Result, error) {
 		return service.StartNewBet(user)
 	}
 }
-func listHandler(service slackbet.BetService) func(string, []string) (string, error) {
-	return func(user string, args []string) (string, error) {
-		return service.ListBets()
+func listHandler(service slackbet.BetService) func(string, []string) (slackbet.Result, error) {
+	return func(user string, args []string) (slackbet.Result, error) {
+		query, err := slackbet.ParseListQuery(args[1:])
+		if err != nil {
+			return nil, errors.New("usage: /bet list " + slackbet.ListQueryUsage)
+		}
+		return service.ListBets(query)
 	}
 }
-func saveBetHandler(service slackbet.BetService) func(string, []string) (string, error) {
-	return func(user string, commands []string) (string, error) {
+
+func saveBetHandler(service slackbet.BetService) func(string, []string) (slackbet.Result, error) {
+	return func(user string, commands []string) (slackbet.Result, error) {
 		if len(commands) < 2 {
-			return "", errors.New("save command format: save <number> <extra info>")
+			return nil, errors.New("save command format: save <number> <extra info>")
 		}
 		number, err := strconv.Atoi(commands[1])
 		if err != nil {
-			return "", errors.New("number is not a valid integer " + commands[1])
+			return nil, errors.New("number is not a valid integer " + commands[1])
 		}
 		extraInfo := ""
 		if len(commands) > 2 {
@@ -43,149 +62,421 @@ func saveBetHandler(service slackbet.BetService) func(string, []string) (string,
 		return service.SaveBet(user, number, extraInfo)
 	}
 }
-func endBetHandler(service slackbet.BetService) func(string, []string) (string, error) {
-	return func(user string, args []string) (string, error) {
+func sealHandler(service slackbet.BetService) func(string, []string) (slackbet.Result, error) {
+	return func(user string, commands []string) (slackbet.Result, error) {
+		if len(commands) < 2 {
+			return nil, errors.New("usage: /bet seal <commitment> <extra info>")
+		}
+		return service.SaveSealedBet(user, commands[1], strings.Join(commands[2:], " "))
+	}
+}
+func revealHandler(service slackbet.BetService) func(string, []string) (slackbet.Result, error) {
+	return func(user string, commands []string) (slackbet.Result, error) {
+		if len(commands) != 3 {
+			return nil, errors.New("usage: /bet reveal <number> <salt>")
+		}
+		number, err := strconv.Atoi(commands[1])
+		if err != nil {
+			return nil, errors.New("number is not a valid integer " + commands[1])
+		}
+		return service.RevealBet(user, number, commands[2])
+	}
+}
+func endBetHandler(service slackbet.BetService) func(string, []string) (slackbet.Result, error) {
+	return func(user string, args []string) (slackbet.Result, error) {
 		return service.EndBet(user)
 	}
 }
 
-func betInfoHandler(service slackbet.BetService) func(string, []string) (string, error) {
-	return func(user string, commands []string) (string, error) {
+func betInfoHandler(service slackbet.BetService) func(string, []string) (slackbet.Result, error) {
+	return func(user string, commands []string) (slackbet.Result, error) {
 		if len(commands) < 2 {
-			return "", errors.New("usage: /bet info <month or id of bet>")
+			return nil, errors.New("usage: /bet info <id of bet, month, month year, yyyy-mm, last month>")
 		}
 		secondArg := commands[1]
-		if isAllInteger(secondArg) {
+		if len(commands) == 2 && slackbet.IsAllInteger(secondArg) {
 			betID, err := strconv.Atoi(secondArg)
 			if err != nil {
-				return "", errors.New("id is not a valid integer " + commands[1])
+				return nil, errors.New("id is not a valid integer " + commands[1])
 			}
 			return service.GetBetInfo(betID)
-		} else {
-			monthIndex := getMonthIndex(secondArg)
-			if monthIndex == -1 {
-				return "", errors.New(secondArg + " is not a valid month.")
-			}
-			return service.GetBetInfoForMonth(monthIndex)
 		}
+		return service.GetBetInfoForPeriod(strings.Join(commands[1:], " "))
 	}
 }
-func whoWinsHandler(service slackbet.BetService) func(string, []string) (string, error) {
-	return func(user string, commands []string) (string, error) {
+func whoWinsHandler(service slackbet.BetService) func(string, []string) (slackbet.Result, error) {
+	return func(user string, commands []string) (slackbet.Result, error) {
 		if len(commands) > 1 {
 			referenceNumber, err := strconv.Atoi(commands[1])
 			if err != nil {
-				return "", errors.New("reference number is not a valid integer " + commands[1])
+				return nil, errors.New("reference number is not a valid integer " + commands[1])
 			}
 			return service.CalculateWhoWins(referenceNumber)
 		} else {
-			return "", errors.New("usage: /bet whowins <number>")
+			return nil, errors.New("usage: /bet whowins <number>")
 		}
 	}
 }
-func saveForHandler(service slackbet.BetService) func(string, []string) (string, error) {
-	return func(user string, commands []string) (string, error) {
-		if !service.IsAuthorizedUser(user) || len(commands) != 3 {
-			return "", errors.New("savefor is not a valid command.")
+func saveForHandler(service slackbet.BetService) func(string, []string) (slackbet.Result, error) {
+	return func(user string, commands []string) (slackbet.Result, error) {
+		if len(commands) != 3 {
+			return nil, errors.New("usage: /bet savefor <user> <number>")
 		}
-		user = commands[1]
 		number, err := strconv.Atoi(commands[2])
 		if err != nil {
-			return "", errors.New("number is not a valid integer " + commands[2])
+			return nil, errors.New("number is not a valid integer " + commands[2])
 		}
-		return service.SaveBet(user, number, "")
+		return service.SaveBetFor(user, commands[1], number)
 	}
 }
-func listAbentUsersHandler(service slackbet.BetService) func(string, []string) (string, error) {
-	return func(user string, commands []string) (string, error) {
-		return service.ListAbsentUsers()
+func listAbentUsersHandler(service slackbet.BetService) func(string, []string) (slackbet.Result, error) {
+	return func(user string, commands []string) (slackbet.Result, error) {
+		return service.ListAbsentUsers(user)
 	}
 }
-func saveWinnerHandler(service slackbet.BetService) func(string, []string) (string, error) {
-	return func(user string, commands []string) (string, error) {
-
-		if !service.IsAuthorizedUser(user) || len(commands) != 3 {
-			return "", errors.New("savefor is not a valid command.")
+func saveWinnerHandler(service slackbet.BetService) func(string, []string) (slackbet.Result, error) {
+	return func(user string, commands []string) (slackbet.Result, error) {
+		if len(commands) != 3 {
+			return nil, errors.New("usage: /bet savewinner <betID> <score>")
 		}
 		winner, err := strconv.Atoi(commands[2])
 		if err != nil {
-			return "", errors.New("winner number is not a valid integer " + commands[2])
+			return nil, errors.New("winner number is not a valid integer " + commands[2])
 		}
 		betID, err := strconv.Atoi(commands[1])
 		if err != nil {
-			return "", errors.New("betID is not a valid integer " + commands[1])
+			return nil, errors.New("betID is not a valid integer " + commands[1])
 		}
-		return service.SaveWinner(betID, winner)
+		return service.SaveWinner(user, betID, winner)
 	}
 }
-func lastInfoHandler(service slackbet.BetService) func(string, []string) (string, error) {
-	return func(user string, commands []string) (string, error) {
-		return service.GetLastEndedBetInfo()
+func adminHandler(service slackbet.BetService) func(string, []string) (slackbet.Result, error) {
+	return func(user string, commands []string) (slackbet.Result, error) {
+		if len(commands) == 2 && commands[1] == "list" {
+			return service.ListRoles()
+		}
+		if len(commands) != 3 {
+			return nil, errors.New("usage: /bet admin add|remove|list <user>")
+		}
+		switch commands[1] {
+		case "add":
+			return service.SetUserRole(user, commands[2], slackbet.RoleAdmin.String())
+		case "remove":
+			return service.SetUserRole(user, commands[2], slackbet.RolePlayer.String())
+		}
+		return nil, errors.New("usage: /bet admin add|remove|list <user>")
 	}
 }
-func getMonthIndex(month string) int {
-	for i, m := range slackbet.Months {
-		if m == month {
-			return i
+func roleHandler(service slackbet.BetService) func(string, []string) (slackbet.Result, error) {
+	return func(user string, commands []string) (slackbet.Result, error) {
+		if len(commands) == 2 && commands[1] == "list" {
+			return service.ListRoles()
 		}
+		if len(commands) != 4 || commands[1] != "set" {
+			return nil, errors.New("usage: /bet role set <user> <" + strings.Join(slackbet.Roles[:], "|") + "> or /bet role list")
+		}
+		return service.SetUserRole(user, commands[2], commands[3])
+	}
+}
+func auditHandler(service slackbet.BetService) func(string, []string) (slackbet.Result, error) {
+	return func(user string, commands []string) (slackbet.Result, error) {
+		args := commands[1:]
+		export := len(args) > 0 && args[0] == "export"
+		if export {
+			args = args[1:]
+		}
+		if len(args) > 1 {
+			return nil, errors.New("usage: /bet audit [export] [betID]")
+		}
+		betID := -1
+		if len(args) == 1 {
+			id, err := strconv.Atoi(args[0])
+			if err != nil {
+				return nil, errors.New("betID is not a valid integer " + args[0])
+			}
+			betID = id
+		}
+		if export {
+			return service.ExportAuditLog(user, betID)
+		}
+		return service.GetAuditLog(user, betID)
+	}
+}
+func unsaveHandler(service slackbet.BetService) func(string, []string) (slackbet.Result, error) {
+	return func(user string, commands []string) (slackbet.Result, error) {
+		if len(commands) != 2 {
+			return nil, errors.New("usage: /bet unsave <user>")
+		}
+		return service.UnsaveBet(user, commands[1])
 	}
-	return -1
 }
 
-func isAllInteger(s string) bool {
-	for _, c := range []byte(s) {
-		if c < '0' || c > '9' {
-			return false
+// betIDHandler handles commands that only take a bet ID, like /bet delete <betID>.
+func betIDHandler(command string, run func(string, int) (*slackbet.Confirmation, error)) func(string, []string) (slackbet.Result, error) {
+	return func(user string, commands []string) (slackbet.Result, error) {
+		if len(commands) != 2 {
+			return nil, errors.New("usage: /bet " + command + " <betID>")
 		}
+		betID, err := strconv.Atoi(commands[1])
+		if err != nil {
+			return nil, errors.New("betID is not a valid integer " + commands[1])
+		}
+		return run(user, betID)
+	}
+}
+func outboxHandler(service slackbet.BetService) func(string, []string) (slackbet.Result, error) {
+	return func(user string, commands []string) (slackbet.Result, error) {
+		usage := errors.New("usage: /bet outbox [list] or /bet outbox replay <id>|all")
+		switch {
+		case len(commands) == 1 || len(commands) == 2 && commands[1] == "list":
+			return service.ListDeadNotifications(user)
+		case len(commands) == 3 && commands[1] == "replay":
+			if commands[2] == "all" {
+				return service.ReplayNotification(user, -1)
+			}
+			id, err := strconv.Atoi(strings.TrimPrefix(commands[2], "#"))
+			if err != nil {
+				return nil, usage
+			}
+			return service.ReplayNotification(user, id)
+		}
+		return nil, usage
+	}
+}
+func undoHandler(service slackbet.BetService) func(string, []string) (slackbet.Result, error) {
+	return func(user string, commands []string) (slackbet.Result, error) {
+		return service.Undo(user)
+	}
+}
+func lastInfoHandler(service slackbet.BetService) func(string, []string) (slackbet.Result, error) {
+	return func(user string, commands []string) (slackbet.Result, error) {
+		return service.GetLastEndedBetInfo()
 	}
-	return true
 }
 
 func writeResponseWithBadRequest(w *http.ResponseWriter, text string) {
 	(*w).WriteHeader(http.StatusBadRequest)
-	fmt.Fprintf(*w, text)
+	fmt.Fprint(*w, text)
 }
-func parseConf(confFileName string) (*slackbet.Conf, error) {
-	file, err := os.Open(confFileName)
+
+// loadConf loads the conf file given by the --config flag, conf.json by default, and applies
+// SLACKBET_* environment variables. The file is optional unless --config is given, which is returned as required.
+func loadConf(args []string, lookupEnv func(string) (string, bool)) (*slackbet.Conf, string, bool, error) {
+	flags := flag.NewFlagSet("slackbet", flag.ContinueOnError)
+	path := flags.String("config", "conf.json", "path of the conf file")
+	err := flags.Parse(args)
 	if err != nil {
-		return nil, err
+		return nil, "", false, err
 	}
-	c := &slackbet.Conf{}
-	err = json.NewDecoder(file).Decode(c)
-	if err != nil {
-		return nil, err
+	required := false
+	flags.Visit(func(f *flag.Flag) {
+		required = required || f.Name == "config"
+	})
+	conf, err := slackbet.LoadConf(*path, required, lookupEnv)
+	return conf, *path, required, err
+}
+
+// populateMux registers the commands of service, their results are rendered by render.
+func populateMux(mux *slackcommander.SlackMux, service slackbet.BetService, render func(slackbet.Result) string, logger *slog.Logger) {
+	register := func(command string, handler func(string, []string) (slackbet.Result, error)) {
+		mux.RegisterCommand(command, metrics.Command(command, func(user string, args []string) (string, error) {
+			result, err := handler(user, args)
+			if err != nil {
+				logger.Info("command failed", "err", err)
+				return "", err
+			}
+			return render(result), nil
+		}))
 	}
-	return c, nil
+	register("start", startHandler(service))
+	register("list", listHandler(service))
+	register("save", saveBetHandler(service))
+	register("end", endBetHandler(service))
+	register("info", betInfoHandler(service))
+	register("whowins", whoWinsHandler(service))
+	register("savefor", saveForHandler(service))
+	register("listabsent", listAbentUsersHandler(service))
+	register("savewinner", saveWinnerHandler(service))
+	register("last", lastInfoHandler(service))
+	register("admin", adminHandler(service))
+	register("role", roleHandler(service))
+	register("audit", auditHandler(service))
+	register("reopen", betIDHandler("reopen", service.ReopenBet))
+	register("unsave", unsaveHandler(service))
+	register("clearwinner", betIDHandler("clearwinner", service.ClearWinner))
+	register("undo", undoHandler(service))
+	register("delete", betIDHandler("delete", service.DeleteBet))
+	register("archive", betIDHandler("archive", service.ArchiveBet))
+	register("restore", betIDHandler("restore", service.RestoreBet))
+	register("purge", betIDHandler("purge", service.PurgeBet))
+	register("outbox", outboxHandler(service))
+	register("seal", sealHandler(service))
+	register("reveal", revealHandler(service))
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
 }
 
-func populateMux(mux *slackcommander.SlackMux, service slackbet.BetService) {
-	mux.RegisterCommand("start", startHandler(service))
-	mux.RegisterCommand("list", listHandler(service))
-	mux.RegisterCommand("save", saveBetHandler(service))
-	mux.RegisterCommand("end", endBetHandler(service))
-	mux.RegisterCommand("info", betInfoHandler(service))
-	mux.RegisterCommand("whowins", whoWinsHandler(service))
-	mux.RegisterCommand("savefor", saveForHandler(service))
-	mux.RegisterCommand("listabsent", listAbentUsersHandler(service))
-	mux.RegisterCommand("savewinner", saveWinnerHandler(service))
-	mux.RegisterCommand("last", lastInfoHandler(service))
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
 }
 
-var mux *slackcommander.SlackMux = &slackcommander.SlackMux{}
+// commandHandler serves slash commands. Every request gets an ID, taken from the X-Request-Id header if there is one,
+// and is handled by a copy of the current service that logs with the request ID, user and command, so that the callbacks
+// it sends later can be told apart.
+func commandHandler(current func() *bet.BetService, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		service := current()
+		requestID := r.Header.Get("X-Request-Id")
+		if requestID == "" {
+			requestID = newRequestID()
+		}
+		w.Header().Set("X-Request-Id", requestID)
+		r.ParseForm()
+		command := ""
+		if args := strings.Fields(r.FormValue("text")); len(args) > 0 {
+			command = args[0]
+		}
+		requestLogger := logger.With("requestId", requestID, "user", r.FormValue("user_name"), "command", command)
+
+		requestService := *service
+		requestService.Logger = requestLogger
+		requestMux := &slackcommander.SlackMux{Token: service.Conf.SlashCommandToken}
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		populateMux(requestMux, &requestService, blockReply(recorder, format.New(service.Conf)), requestLogger)
+		requestMux.SlackHandler()(recorder, r)
+		requestLogger.Info("request handled", "status", recorder.status, "duration", time.Since(start))
+	}
+}
+
+// blockReply renders results as a JSON Slack message with Block Kit blocks, errors are still replied as plain text.
+func blockReply(w http.ResponseWriter, formatter *format.Formatter) func(slackbet.Result) string {
+	return func(result slackbet.Result) string {
+		data, err := json.Marshal(formatter.Message(result))
+		if err != nil {
+			return formatter.Text(result)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		return string(data)
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
 
 func main() {
-	conf, err := parseConf("conf.json")
+	conf, confPath, confRequired, err := loadConf(os.Args[1:], os.LookupEnv)
 	if err != nil {
-		fmt.Println("conf cannot be read", err)
-		return
+		slog.Error("conf cannot be loaded", "err", err)
+		os.Exit(1)
+	}
+	logger := conf.NewLogger(os.Stderr)
+	slog.SetDefault(logger)
+	logger.Info("loaded conf", "path", confPath)
+	for _, warning := range conf.Warnings() {
+		logger.Warn(warning)
+	}
+	slackService := slack.NewService(conf.PostToken)
+	slackService.Logger = logger
+	redisRepo := &repo.RedisRepo{Url: conf.RedisAddress(), Location: conf.Location(), Logger: logger}
+	migrated, err := redisRepo.MigrateDates()
+	if err != nil {
+		logger.Error("dates cannot be migrated", "err", err)
+		os.Exit(1)
 	}
-	slackService := &slackcommander.SlackService{PostToken: conf.PostToken}
-	service := &bet.BetService{Repo: &repo.RedisRepo{Url: conf.RedisUrl}, Conf: conf, SlackService: slackService}
-	mux.Token = service.Conf.SlashCommandToken
-	populateMux(mux, service)
-	http.HandleFunc("/bet", mux.SlackHandler())
+	if migrated > 0 {
+		logger.Info("migrated dates", "bets", migrated)
+	}
+	_, err = redisRepo.IndexPeriods()
+	if err != nil {
+		logger.Error("periods cannot be indexed", "err", err)
+		os.Exit(1)
+	}
+	var betRepo repo.Repo = &metrics.Repo{Repo: redisRepo}
+	if conf.CacheBets {
+		cachedRepo := &repo.CachedRepo{Repo: betRepo}
+		metrics.RegisterCache(metrics.Registry, cachedRepo)
+		betRepo = cachedRepo
+	}
+	notifications := outbox.New(betRepo, slackService)
+	notifications.Logger = logger
+	workerCtx, stopWorker := context.WithCancel(context.Background())
+	workerDone := make(chan struct{})
+	go func() {
+		notifications.Run(workerCtx)
+		close(workerDone)
+	}()
+	service := &bet.BetService{Repo: betRepo, Conf: conf, SlackService: notifications, Uploader: slackService, Logger: logger, Callbacks: &sync.WaitGroup{}}
+	confReloader := newReloader(confPath, confRequired, os.LookupEnv, service, logger)
+	confReloader.setPostToken = slackService.SetPostToken
+	go confReloader.Watch(workerCtx, reloadInterval)
+	metrics.RegisterOpenBet(metrics.Registry, func() (int, error) {
+		return confReloader.Service().CountOpenBetParticipants()
+	})
+	http.HandleFunc("/bet", commandHandler(confReloader.Service, logger))
+	http.Handle("/api/v1/", apiHandler(confReloader.Service, logger))
+	http.Handle("/dashboard/", dashboardHandler(confReloader.Service, logger))
+	http.Handle("/metrics", metrics.Handler())
+	http.HandleFunc("/healthz", healthHandler)
+	http.HandleFunc("/readyz", readyHandler([]dependency{
+		{Name: "redis", Check: betRepo.Ping},
+		{Name: "slack", Check: slackService.CheckToken},
+	}))
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprintf(w, "Hello mate.")
 	})
-	http.ListenAndServe(":"+conf.Port, nil)
+	server := &http.Server{Addr: ":" + conf.Port}
+	go func() {
+		logger.Info("listening", "port", conf.Port)
+		if err := server.ListenAndServe(); err != http.ErrServerClosed {
+			logger.Error("server stopped", "err", err)
+			os.Exit(1)
+		}
+	}()
+
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	go func() {
+		for range hangup {
+			confReloader.Reload()
+		}
+	}()
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, os.Interrupt)
+	<-stop
+	shutdown(server, confReloader.Service(), notifications, func() {
+		stopWorker()
+		<-workerDone
+	}, logger)
+}
+
+// shutdownTimeout is how long shutdown waits for requests and callbacks in progress.
+const shutdownTimeout = 20 * time.Second
+
+// shutdown stops accepting requests, then waits for the requests being handled and the callbacks
+// being queued, stops the outbox worker and delivers the notifications that are due, until shutdownTimeout.
+// Notifications that are not delivered are kept in the repo for the next start.
+func shutdown(server *http.Server, service *bet.BetService, notifications *outbox.Outbox, stopWorker func(), logger *slog.Logger) {
+	logger.Info("shutting down")
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Error("requests in progress are dropped", "err", err)
+	}
+	if err := service.WaitCallbacks(ctx); err != nil {
+		logger.Error("callbacks in progress are dropped", "err", err)
+	}
+	stopWorker()
+	if err := notifications.Flush(ctx); err != nil {
+		logger.Error("notifications are left for the next start", "err", err)
+		return
+	}
+	logger.Info("shut down")
 }
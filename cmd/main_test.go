@@ -1,16 +1,22 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/mediocregopher/radix.v2/redis"
 	"github.com/mtyurt/slackbet"
 	"github.com/mtyurt/slackbet/bet"
+	"github.com/mtyurt/slackbet/format"
 	"github.com/mtyurt/slackbet/repo"
 	"github.com/mtyurt/slackcommander"
 )
@@ -26,10 +32,8 @@ func TestComplexBet(t *testing.T) {
 		t.Fatal(err)
 	}
 	cli.Cmd("FLUSHALL")
-	mux.Token = slacktoken
-	populateMux(mux, service)
-	ts := httptest.NewServer(http.HandlerFunc(mux.SlackHandler()))
-	defer ts.Close()
+	mux := &slackcommander.SlackMux{Token: slacktoken}
+	populateMux(mux, service, format.New(service.Conf).Text, slog.Default())
 
 	params := make(url.Values)
 	params.Add("token", slacktoken)
@@ -57,6 +61,7 @@ func TestComplexBet(t *testing.T) {
 	if resp := betWithParams(params, service, mux); strings.Contains(resp, "75") || strings.Contains(resp, "100") || !strings.Contains(resp, "open") {
 		t.Fatal("response contains confidential info", resp)
 	}
+	service.WaitCallbacks(context.Background())
 	params.Set("text", "end")
 	params.Set("user_name", "sezgin")
 	if resp := betWithParams(params, service, mux); resp != "ended bet[1] successfully" {
@@ -67,16 +72,85 @@ func TestComplexBet(t *testing.T) {
 	if strings.Contains(resp, "250") || !strings.Contains(resp, "100") || !strings.Contains(resp, "omer") || !strings.Contains(resp, "tarik") || !strings.Contains(resp, "end") {
 		t.Fatal("response does not contain necessary info", resp)
 	}
-	body := mockService.sentCallback
+	service.WaitCallbacks(context.Background())
+	body := mockService.lastCallback()
 	if strings.Contains(body, "250") || !strings.Contains(body, "100") || !strings.Contains(body, "omer") || !strings.Contains(body, "tarik") || !strings.Contains(body, "end") {
 		t.Log(body)
 		t.Fatal("body is wrong")
 	}
 }
 
+func TestCommandHandlerLogsRequest(t *testing.T) {
+	service := mockService()
+	cli, err := openRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli.Cmd("FLUSHALL")
+	var logs bytes.Buffer
+	logger := (&slackbet.Conf{LogFormat: "json"}).NewLogger(&logs)
+
+	params := url.Values{"token": {slacktoken}, "user_name": {"omer"}, "text": {"start"}}
+	req := httptest.NewRequest("POST", "/bet", strings.NewReader(params.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Request-Id", "req-1")
+	recorder := httptest.NewRecorder()
+	commandHandler(func() *bet.BetService { return service }, logger)(recorder, req)
+	if recorder.Header().Get("X-Request-Id") != "req-1" {
+		t.Fatal("request id is not returned", recorder.Header())
+	}
+	if recorder.Header().Get("Content-Type") == "application/json" {
+		t.Fatal("errors should be replied as text", recorder.Body.String())
+	}
+
+	params.Set("text", "info 1")
+	params.Set("user_name", "sezgin")
+	service.StartNewBet("sezgin")
+	req = httptest.NewRequest("POST", "/bet", strings.NewReader(params.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder = httptest.NewRecorder()
+	commandHandler(func() *bet.BetService { return service }, logger)(recorder, req)
+	var message format.Message
+	if err := json.Unmarshal(recorder.Body.Bytes(), &message); err != nil || recorder.Header().Get("Content-Type") != "application/json" ||
+		len(message.Blocks) == 0 || message.Blocks[0].Text.Text != "Bet #1" || message.Text == "" {
+		t.Fatal("bet should be replied with blocks", err, recorder.Body.String())
+	}
+	for _, field := range []string{`"requestId":"req-1"`, `"user":"omer"`, `"command":"start"`, `"msg":"command failed"`, `"msg":"request handled"`} {
+		if !strings.Contains(logs.String(), field) {
+			t.Fatal("logs should contain", field, "but was", logs.String())
+		}
+	}
+}
+
+func TestLoadConf(t *testing.T) {
+	env := map[string]string{"SLACKBET_POST_TOKEN": "secret-token", "SLACKBET_ADMINS": "omer, sezgin"}
+	lookupEnv := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+	conf, path, required, err := loadConf([]string{"--config", "../conf.example.json"}, lookupEnv)
+	if err != nil || path != "../conf.example.json" || !required {
+		t.Fatal("conf should be loaded", err, path, required)
+	}
+	if conf.PostToken != "secret-token" || !reflect.DeepEqual(conf.Admins, []string{"omer", "sezgin"}) || conf.Channel != "#general" {
+		t.Fatal("environment should override conf", conf)
+	}
+	_, _, _, err = loadConf([]string{"--config", "missing.json"}, lookupEnv)
+	if err == nil {
+		t.Fatal("conf given by --config should exist")
+	}
+	_, _, _, err = loadConf(nil, lookupEnv)
+	if err == nil || err.Error() != "invalid conf: slashCommandToken is required, set it in conf or SLACKBET_SLASH_COMMAND_TOKEN; channel is required, set it in conf or SLACKBET_CHANNEL." {
+		t.Fatal("conf without a file should be validated", err)
+	}
+}
+
 func TestExampleConf(t *testing.T) {
 
-	conf, err := parseConf("../conf.example.json")
+	conf, err := slackbet.ReadConf("../conf.example.json")
+	if err == nil {
+		err = conf.Validate()
+	}
 	if err != nil || conf == nil {
 		t.Fatal("error in initialization, err:", err, "conf:", conf)
 	}
@@ -95,7 +169,7 @@ func TestExampleConf(t *testing.T) {
 	if conf.SlashCommandToken != "8sLyRlhvsFwnZNOT1bpOxuocv1NnvZ1u" {
 		t.Fatal("slash command token is wrong:", conf.SlashCommandToken)
 	}
-	if conf.RedisUrl != "http://localhost:6379" {
+	if conf.RedisUrl != "localhost:37564" {
 		t.Fatal("redis url is wrong:", conf.RedisUrl)
 	}
 	if conf.Port != "37564" {
@@ -116,6 +190,7 @@ func betWithParams(params url.Values, service slackbet.BetService, mux *slackcom
 
 type MockService struct {
 	channelMembers []string
+	mu             sync.Mutex
 	sentCallback   string
 }
 
@@ -133,10 +208,18 @@ func (service *MockService) GetChannelMembers(channelID string) ([]string, error
 }
 
 func (service *MockService) SendCallback(text string, channel string) {
+	service.mu.Lock()
+	defer service.mu.Unlock()
 	service.sentCallback = text
 }
+
+func (service *MockService) lastCallback() string {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+	return service.sentCallback
+}
 func mockService() *bet.BetService {
 	c := &slackbet.Conf{SlashCommandToken: slacktoken, Admins: []string{"sezgin", "abdurrahim"}}
-	mockService := bet.BetService{Conf: c, Repo: &repo.RedisRepo{Url: "localhost:37564"}, SlackService: &MockService{}}
+	mockService := bet.BetService{Conf: c, Repo: &repo.RedisRepo{Url: "localhost:37564"}, SlackService: &MockService{}, Callbacks: &sync.WaitGroup{}}
 	return &mockService
 }
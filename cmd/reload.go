@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mtyurt/slackbet"
+	"github.com/mtyurt/slackbet/bet"
+)
+
+// reloadInterval is how often the conf file is checked for changes.
+const reloadInterval = 5 * time.Second
+
+// reloader keeps the service that serves commands and replaces it when the conf changes.
+// A conf that cannot be loaded is logged and the running one is kept.
+type reloader struct {
+	path      string
+	required  bool
+	lookupEnv func(string) (string, bool)
+	// setPostToken is called with the new bot token when it changes.
+	setPostToken func(string)
+	logger       *slog.Logger
+	service      atomic.Pointer[bet.BetService]
+	mu           sync.Mutex
+	modTime      time.Time
+}
+
+func newReloader(path string, required bool, lookupEnv func(string) (string, bool), service *bet.BetService, logger *slog.Logger) *reloader {
+	r := &reloader{path: path, required: required, lookupEnv: lookupEnv, setPostToken: func(string) {}, logger: logger}
+	r.service.Store(service)
+	if info, err := os.Stat(path); err == nil {
+		r.modTime = info.ModTime()
+	}
+	return r
+}
+
+// Service returns the service with the current conf.
+func (r *reloader) Service() *bet.BetService {
+	return r.service.Load()
+}
+
+// Reload loads the conf again and swaps the service if it is valid.
+// returns error if the conf cannot be loaded, the current conf is kept then.
+func (r *reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	conf, err := slackbet.LoadConf(r.path, r.required, r.lookupEnv)
+	if err != nil {
+		r.logger.Error("conf cannot be reloaded, keeping the current conf", "path", r.path, "err", err)
+		return err
+	}
+	current := r.service.Load()
+	changes := conf.Changes(current.Conf)
+	if len(changes) == 0 {
+		r.logger.Info("conf is not changed", "path", r.path)
+		return nil
+	}
+	var restart []string
+	for _, key := range changes {
+		if slices.Contains(slackbet.RestartKeys, key) {
+			restart = append(restart, key)
+		}
+	}
+	if conf.PostToken != current.Conf.PostToken {
+		r.setPostToken(conf.PostToken)
+	}
+	conf.KeepRestartKeys(current.Conf)
+	service := *current
+	service.Conf = conf
+	r.service.Store(&service)
+	r.logger.Info("reloaded conf", "path", r.path, "changed", changes)
+	if len(restart) > 0 {
+		r.logger.Warn("some changes take effect after restart", "fields", restart)
+	}
+	return nil
+}
+
+// Watch reloads the conf when the modification time of the file changes, until ctx is done.
+func (r *reloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		info, err := os.Stat(r.path)
+		if err != nil || info.ModTime().Equal(r.modTime) {
+			continue
+		}
+		r.modTime = info.ModTime()
+		r.Reload()
+	}
+}
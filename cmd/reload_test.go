@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mtyurt/slackbet"
+	"github.com/mtyurt/slackbet/bet"
+)
+
+const reloadConf = `{"admins":["tarik"],"postToken":"%s","channel":"%s","slashCommandToken":"slacktoken","port":"37564"}`
+
+func writeConf(t *testing.T, path string, postToken string, channel string) {
+	conf := strings.Replace(strings.Replace(reloadConf, "%s", postToken, 1), "%s", channel, 1)
+	if err := os.WriteFile(path, []byte(conf), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "conf.json")
+	writeConf(t, path, "token", "#general")
+	noEnv := func(string) (string, bool) { return "", false }
+	service := mockService()
+	conf, err := slackbet.LoadConf(path, true, noEnv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.Conf = conf
+	var logs bytes.Buffer
+	r := newReloader(path, true, noEnv, service, slog.New(slog.NewTextHandler(&logs, nil)))
+	var postToken string
+	r.setPostToken = func(token string) { postToken = token }
+
+	writeConf(t, path, "new-token", "#random")
+	if err := r.Reload(); err != nil {
+		t.Fatal("conf should be reloaded", err)
+	}
+	current := r.Service()
+	if current == service || current.Conf.Channel != "#random" || postToken != "new-token" || current.Callbacks != service.Callbacks {
+		t.Fatal("service is not swapped", current.Conf, postToken)
+	}
+	if !strings.Contains(logs.String(), `changed="[postToken channel]"`) {
+		t.Fatal("changes are not logged", logs.String())
+	}
+
+	os.WriteFile(path, []byte(`{"admins":["tarik"],"postToken":"new-token","channel":"#random","slashCommandToken":"slacktoken","port":"37564","timezone":"Europe/Istanbul","dateFormat":"2006-01-02"}`), 0600)
+	if err := r.Reload(); err != nil {
+		t.Fatal("conf should be reloaded", err)
+	}
+	current = r.Service()
+	if current.Conf.DateFormat != "2006-01-02" || current.Conf.Timezone != "UTC" || current.Conf.Location() != time.UTC {
+		t.Fatal("timezone should be kept until restart", current.Conf)
+	}
+	if !strings.Contains(logs.String(), `fields=[timezone]`) {
+		t.Fatal("restart is not logged", logs.String())
+	}
+
+	os.WriteFile(path, []byte(`{"channel":"#broken"}`), 0600)
+	if err := r.Reload(); err == nil || !strings.HasPrefix(err.Error(), "invalid conf:") {
+		t.Fatal("invalid conf should not be loaded", err)
+	}
+	if r.Service() != current {
+		t.Fatal("current conf should be kept")
+	}
+}
+
+func TestWatchConf(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "conf.json")
+	writeConf(t, path, "token", "#general")
+	noEnv := func(string) (string, bool) { return "", false }
+	conf, err := slackbet.LoadConf(path, true, noEnv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := &bet.BetService{Conf: conf, Callbacks: &sync.WaitGroup{}}
+	r := newReloader(path, true, noEnv, service, slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Watch(ctx, 10*time.Millisecond)
+
+	writeConf(t, path, "token", "#random")
+	os.Chtimes(path, time.Now(), time.Now().Add(time.Minute))
+	deadline := time.Now().Add(2 * time.Second)
+	for r.Service() == service && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if r.Service().Conf.Channel != "#random" {
+		t.Fatal("changed conf file should be reloaded")
+	}
+}
@@ -0,0 +1,240 @@
+// Command slackbet runs bet commands against the configured repo without Slack, for scripts and emergencies.
+//
+//	slackbet [--config conf.json] [--user name] [--format table|json] [--no-notify] <command> [args]
+//
+// Commands are run as --user, the first admin by default, with the same permission checks as /bet.
+// Callbacks are queued in the outbox and delivered by the server, they are not queued and charts are not uploaded with --no-notify.
+// Commands that change bets are refused if cacheBets is set, since the server's cache would not see their changes.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/mtyurt/slackbet"
+	"github.com/mtyurt/slackbet/bet"
+	"github.com/mtyurt/slackbet/format"
+	"github.com/mtyurt/slackbet/outbox"
+	"github.com/mtyurt/slackbet/repo"
+	"github.com/mtyurt/slackbet/slack"
+)
+
+const usage = `usage: slackbet [flags] <command> [args]
+
+commands:
+  start                        start a new bet
+  end                          end the open bet
+  list [query]                 list bets, query is ` + slackbet.ListQueryUsage + `
+  info <betID>|<period>        show a bet, or the bet of a month like "march 2025"
+  save-for <user> <number>     save a bet for a user in the open bet
+  set-winner <betID> <score>   save the winner score of a bet
+  absent                       post the users who have not placed a bet in the open bet
+
+flags:
+`
+
+// writeCommands are the commands that change bets in Redis.
+var writeCommands = map[string]bool{"start": true, "end": true, "save-for": true, "set-winner": true}
+
+// callbackTimeout is how long the command waits for its callbacks to be queued.
+const callbackTimeout = 10 * time.Second
+
+// callback is a message that a command posts to Slack.
+type callback struct {
+	Channel string `json:"channel"`
+	Text    string `json:"text"`
+}
+
+// result is the output of a command in JSON format.
+type result struct {
+	Command   string      `json:"command"`
+	Ok        bool        `json:"ok"`
+	Result    interface{} `json:"result,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	Callbacks []callback  `json:"callbacks,omitempty"`
+}
+
+// recordingSlack is a slackbet.SlackService that keeps the callbacks of a command to print them,
+// and passes them to Slack if notify is true.
+type recordingSlack struct {
+	slack     slackbet.SlackService
+	notify    bool
+	mu        sync.Mutex
+	callbacks []callback
+}
+
+func (r *recordingSlack) GetChannelMembers(channelID string) ([]string, error) {
+	return r.slack.GetChannelMembers(channelID)
+}
+
+func (r *recordingSlack) SendCallback(text string, channel string) {
+	r.mu.Lock()
+	r.callbacks = append(r.callbacks, callback{Channel: channel, Text: text})
+	r.mu.Unlock()
+	if r.notify {
+		r.slack.SendCallback(text, channel)
+	}
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.LookupEnv, os.Stdout, os.Stderr))
+}
+
+// run runs the command in args and returns the exit code, 1 if the command fails and 2 if it is not valid.
+func run(args []string, lookupEnv func(string) (string, bool), stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("slackbet", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	path := flags.String("config", "conf.json", "path of the conf file, SLACKBET_* environment variables override it")
+	user := flags.String("user", "", "user that runs the command, the first admin by default")
+	outputFormat := flags.String("format", "table", "output format, table or json")
+	noNotify := flags.Bool("no-notify", false, "don't post callbacks to Slack, only print them")
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 || (*outputFormat != "table" && *outputFormat != "json") {
+		flags.Usage()
+		return 2
+	}
+	required := false
+	flags.Visit(func(f *flag.Flag) {
+		required = required || f.Name == "config"
+	})
+	conf, err := slackbet.LoadConf(*path, required, lookupEnv)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	if conf.CacheBets && writeCommands[flags.Arg(0)] {
+		fmt.Fprintln(stderr, "error:", flags.Arg(0), "is disabled while cacheBets is set, the server's cache would not see the change")
+		return 1
+	}
+	if *user == "" {
+		*user = conf.Admins[0]
+	}
+	logger := conf.NewLogger(stderr)
+	for _, warning := range conf.Warnings() {
+		logger.Warn(warning)
+	}
+	redisRepo := &repo.RedisRepo{Url: conf.RedisAddress(), Location: conf.Location(), Logger: logger}
+	slackService := slack.NewService(conf.PostToken)
+	slackService.Logger = logger
+	notifications := outbox.New(redisRepo, slackService)
+	notifications.Logger = logger
+	recorder := &recordingSlack{slack: notifications, notify: !*noNotify}
+	service := &bet.BetService{Repo: redisRepo, Conf: conf, SlackService: recorder, Logger: logger, Callbacks: &sync.WaitGroup{}}
+	if !*noNotify {
+		service.Uploader = slackService
+	}
+
+	output, err := execute(service, *user, flags.Args())
+	ctx, cancel := context.WithTimeout(context.Background(), callbackTimeout)
+	defer cancel()
+	if waitErr := service.WaitCallbacks(ctx); waitErr != nil {
+		logger.Warn("callbacks are not queued in time", "err", waitErr)
+	}
+	recorder.mu.Lock()
+	callbacks := recorder.callbacks
+	recorder.mu.Unlock()
+	formatter := format.New(conf)
+	if *outputFormat == "json" {
+		r := result{Command: flags.Arg(0), Ok: err == nil, Error: errorString(err), Callbacks: callbacks}
+		if err == nil {
+			r.Result = formatter.JSON(output)
+		}
+		writeJSON(stdout, r)
+	} else if err != nil {
+		fmt.Fprintln(stderr, "error:", err)
+	} else {
+		writeTable(stdout, formatter.Table(output), callbacks)
+	}
+	var invalid usageError
+	if errors.As(err, &invalid) {
+		return 2
+	}
+	if err != nil {
+		return 1
+	}
+	return 0
+}
+
+// usageError is returned for commands that are not valid.
+type usageError struct {
+	error
+}
+
+var errUsage = usageError{errors.New("invalid command, run slackbet --help for usage")}
+
+// execute runs a command with the same arguments as the server's /bet command, as user.
+func execute(service slackbet.BetService, user string, args []string) (slackbet.Result, error) {
+	switch {
+	case args[0] == "start" && len(args) == 1:
+		return service.StartNewBet(user)
+	case args[0] == "end" && len(args) == 1:
+		return service.EndBet(user)
+	case args[0] == "list":
+		query, err := slackbet.ParseListQuery(args[1:])
+		if err != nil {
+			return nil, usageError{err}
+		}
+		return service.ListBets(query)
+	case args[0] == "info" && len(args) > 1:
+		if betID, err := strconv.Atoi(args[1]); err == nil && len(args) == 2 {
+			return service.GetBetInfo(betID)
+		}
+		return service.GetBetInfoForPeriod(strings.Join(args[1:], " "))
+	case args[0] == "save-for" && len(args) == 3:
+		number, err := strconv.Atoi(args[2])
+		if err != nil {
+			return nil, errors.New("number is not a valid integer " + args[2])
+		}
+		return service.SaveBetFor(user, args[1], number)
+	case args[0] == "set-winner" && len(args) == 3:
+		betID, err := strconv.Atoi(args[1])
+		if err != nil {
+			return nil, errors.New("betID is not a valid integer " + args[1])
+		}
+		score, err := strconv.Atoi(args[2])
+		if err != nil {
+			return nil, errors.New("score is not a valid integer " + args[2])
+		}
+		return service.SaveWinner(user, betID, score)
+	case args[0] == "absent" && len(args) == 1:
+		return service.ListAbsentUsers(user)
+	}
+	return nil, errUsage
+}
+
+func writeJSON(w io.Writer, r result) {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(r)
+}
+
+// writeTable prints the table followed by the callbacks.
+func writeTable(w io.Writer, table string, callbacks []callback) {
+	fmt.Fprintln(w, strings.TrimRight(table, "\n"))
+	for _, c := range callbacks {
+		fmt.Fprintln(w, "\ncallback to "+c.Channel+":\n"+c.Text)
+	}
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
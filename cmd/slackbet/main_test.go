@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mediocregopher/radix.v2/redis"
+	"github.com/mtyurt/slackbet/format"
+)
+
+const conf = `{"admins":["sezgin"],"postToken":"token","channel":"#general","channelId":"C1","slashCommandToken":"slacktoken","redisUrl":"localhost:37564"}`
+
+func runCommand(t *testing.T, args ...string) (int, string, string) {
+	return runCommandWithConf(t, conf, args...)
+}
+
+func runCommandWithConf(t *testing.T, conf string, args ...string) (int, string, string) {
+	path := filepath.Join(t.TempDir(), "conf.json")
+	if err := os.WriteFile(path, []byte(conf), 0600); err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	noEnv := func(string) (string, bool) { return "", false }
+	code := run(append([]string{"--config", path, "--no-notify"}, args...), noEnv, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestCommands(t *testing.T) {
+	client, err := redis.Dial("tcp", "localhost:37564")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Cmd("FLUSHALL")
+	client.Close()
+
+	if code, out, _ := runCommand(t, "start"); code != 0 || !strings.HasPrefix(out, "started bet[1] successfully\n") {
+		t.Fatal("bet should be started", code, out)
+	}
+	if code, _, _ := runCommand(t, "save-for", "omer", "100"); code != 0 {
+		t.Fatal("bet should be saved", code)
+	}
+	if code, _, errOut := runCommand(t, "--user", "omer", "save-for", "tarik", "200"); code != 1 || !strings.Contains(errOut, "error:") {
+		t.Fatal("player should not save for others", code, errOut)
+	}
+	if code, out, _ := runCommand(t, "end"); code != 0 || !strings.Contains(out, "callback to #general:") || !strings.Contains(out, "omer") {
+		t.Fatal("bet should be ended with a callback", code, out)
+	}
+	if code, _, _ := runCommand(t, "set-winner", "1", "120"); code != 0 {
+		t.Fatal("winner should be saved", code)
+	}
+
+	code, out, _ := runCommand(t, "--format", "json", "info", "1")
+	var info struct {
+		Command string
+		Ok      bool
+		Result  format.Bet
+	}
+	if err := json.Unmarshal([]byte(out), &info); err != nil || code != 0 || !info.Ok || info.Command != "info" ||
+		len(info.Result.Entries) != 1 || info.Result.Entries[0] != (format.Entry{User: "omer", Number: 100}) {
+		t.Fatal("info should be printed as JSON", code, out, err)
+	}
+	code, out, _ = runCommand(t, "--format", "json", "list", "closed")
+	var list struct {
+		Result format.BetPage
+	}
+	if err := json.Unmarshal([]byte(out), &list); err != nil || code != 0 || len(list.Result.Bets) != 1 || *list.Result.Bets[0].WinnerScore != 120 {
+		t.Fatal("list should be printed as JSON", code, out, err)
+	}
+	if code, out, _ = runCommand(t, "info", "1"); code != 0 || !strings.Contains(out, "1.  omer  100\n") {
+		t.Fatal("info should be printed as a table", code, out)
+	}
+}
+
+func TestInvalidCommands(t *testing.T) {
+	for _, args := range [][]string{{}, {"whatever"}, {"start", "now"}, {"--format", "xml", "list"}, {"list", "page", "two"}} {
+		if code, _, _ := runCommand(t, args...); code != 2 {
+			t.Fatal("command should be invalid", args, code)
+		}
+	}
+}
+
+func TestWriteCommandsWithCachedBets(t *testing.T) {
+	client, err := redis.Dial("tcp", "localhost:37564")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Cmd("FLUSHALL")
+	defer client.Close()
+
+	cached := strings.Replace(conf, "}", `,"cacheBets":true}`, 1)
+	if code, _, errOut := runCommandWithConf(t, cached, "start"); code != 1 || !strings.Contains(errOut, "start is disabled while cacheBets is set") {
+		t.Fatal("start should be refused", code, errOut)
+	}
+	if exists, _ := client.Cmd("EXISTS", "LastID").Int(); exists != 0 {
+		t.Fatal("no bet should be started")
+	}
+	if code, _, _ := runCommandWithConf(t, cached, "list"); code != 0 {
+		t.Fatal("list should be allowed", code)
+	}
+}
@@ -0,0 +1,326 @@
+package slackbet
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Defaults of Conf fields that are not set.
+const (
+	DefaultPort     = "8080"
+	DefaultRedisUrl = "localhost:37564"
+)
+
+// EnvPrefix is the prefix of environment variables that override Conf fields.
+const EnvPrefix = "SLACKBET_"
+
+// Conf is the configuration of the bot. Every field can be overridden by an environment variable
+// named after its JSON key, like SLACKBET_POST_TOKEN for postToken, see ApplyEnv.
+type Conf struct {
+	// Admins are owners of the bot, their role can't be changed by commands.
+	Admins            []string `json:"admins"`
+	PostToken         string   `json:"postToken"`
+	Channel           string   `json:"channel"`
+	ChannelID         string   `json:"channelId"`
+	SlashCommandToken string   `json:"slashCommandToken"`
+	// RedisUrl is host:port or redis://host:port of the Redis server, other schemes are ignored. Defaults to DefaultRedisUrl.
+	RedisUrl string `json:"redisUrl"`
+	// Port is the HTTP port. Defaults to DefaultPort.
+	Port string `json:"port"`
+	// Permissions maps command names to role names, see DefaultPermissions.
+	Permissions map[string]string `json:"permissions"`
+	// Timezone is the IANA name of the team's timezone, like "Europe/Istanbul". Defaults to UTC.
+	Timezone string `json:"timezone"`
+	// DateFormat is the Go layout that dates are displayed in. Defaults to TimeFormat.
+	DateFormat string `json:"dateFormat"`
+	// CacheBets keeps closed bets in memory, only enable it if this is the only instance using the Redis.
+	CacheBets bool `json:"cacheBets"`
+	// LogLevel is one of debug, info, warn and error. Defaults to info.
+	LogLevel string `json:"logLevel"`
+	// LogFormat is text or json. Defaults to text.
+	LogFormat string `json:"logFormat"`
+	// APIKeys maps keys of the JSON API to the users that they act as, the API is disabled if it is empty.
+	APIKeys map[string]string `json:"apiKeys"`
+	// DashboardSecret is the password of the web dashboard under /dashboard/, the dashboard is disabled if it is empty.
+	DashboardSecret string `json:"dashboardSecret"`
+	// UploadCharts uploads a histogram of the guesses to ChannelID when a bet ends, the bot needs the files:write scope.
+	UploadCharts bool `json:"uploadCharts"`
+	// OpenBetReveal is what everyone sees of the open bet: participants, count or nothing. Defaults to participants.
+	// Users with the seeguesses permission see every guess, guesses of closed bets are public.
+	OpenBetReveal string `json:"openBetReveal"`
+	// SealedBets starts bets sealed, their guesses are stored as salted hash commitments that users reveal after the bet ends.
+	SealedBets bool `json:"sealedBets"`
+}
+
+// minAPIKeyLength is the minimum length of an API key and the dashboard secret.
+const minAPIKeyLength = 16
+
+// Location returns the team's timezone, UTC if it is not set or not valid.
+func (c *Conf) Location() *time.Location {
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// DateLayout returns the layout that dates are displayed in.
+func (c *Conf) DateLayout() string {
+	if c.DateFormat == "" {
+		return TimeFormat
+	}
+	return c.DateFormat
+}
+
+// NewLogger returns a logger that writes to w in the configured level and format.
+// An unknown level is treated as info.
+func (c *Conf) NewLogger(w io.Writer) *slog.Logger {
+	var level slog.Level
+	if c.LogLevel != "" {
+		if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+			level = slog.LevelInfo
+		}
+	}
+	options := &slog.HandlerOptions{Level: level}
+	if strings.EqualFold(c.LogFormat, "json") {
+		return slog.New(slog.NewJSONHandler(w, options))
+	}
+	return slog.New(slog.NewTextHandler(w, options))
+}
+
+// LoadConf reads the conf file at path, applies environment variables with lookupEnv, fills in defaults
+// and validates the result. A missing file is only an error if required is true.
+func LoadConf(path string, required bool, lookupEnv func(string) (string, bool)) (*Conf, error) {
+	conf, err := ReadConf(path)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		conf, err = &Conf{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = conf.ApplyEnv(lookupEnv)
+	if err != nil {
+		return nil, err
+	}
+	conf.SetDefaults()
+	err = conf.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// ReadConf decodes the conf file at path as is, without environment variables, defaults and validation.
+func ReadConf(path string) (*Conf, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	c := &Conf{}
+	err = json.NewDecoder(file).Decode(c)
+	if err != nil {
+		return nil, errors.New("conf " + path + " cannot be decoded: " + err.Error())
+	}
+	return c, nil
+}
+
+// EnvName returns the environment variable of a JSON key of Conf, like SLACKBET_CHANNEL_ID for channelId.
+func EnvName(key string) string {
+	name := EnvPrefix
+	for i, r := range key {
+		if unicode.IsUpper(r) && i > 0 {
+			name += "_"
+		}
+		name += string(unicode.ToUpper(r))
+	}
+	return name
+}
+
+// ApplyEnv overrides fields with the environment variables that are set. Lists are comma separated,
+// like "tarik,omer", and permissions are comma separated command=role pairs, like "start=moderator,end=moderator".
+func (c *Conf) ApplyEnv(lookupEnv func(string) (string, bool)) error {
+	value := reflect.ValueOf(c).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		key := strings.Split(value.Type().Field(i).Tag.Get("json"), ",")[0]
+		name := EnvName(key)
+		env, ok := lookupEnv(name)
+		if !ok {
+			continue
+		}
+		field := value.Field(i)
+		switch field.Interface().(type) {
+		case string:
+			field.SetString(env)
+		case bool:
+			b, err := strconv.ParseBool(env)
+			if err != nil {
+				return errors.New(name + " should be true or false, it is " + env + ".")
+			}
+			field.SetBool(b)
+		case []string:
+			field.Set(reflect.ValueOf(splitList(env)))
+		case map[string]string:
+			m := make(map[string]string)
+			for _, pair := range splitList(env) {
+				k, v, ok := strings.Cut(pair, "=")
+				if !ok {
+					return errors.New(name + " should be a list of key=value pairs, " + pair + " is not.")
+				}
+				m[strings.TrimSpace(k)] = strings.TrimSpace(v)
+			}
+			field.Set(reflect.ValueOf(m))
+		}
+	}
+	return nil
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// SetDefaults fills in the fields that are not set.
+func (c *Conf) SetDefaults() {
+	if c.Port == "" {
+		c.Port = DefaultPort
+	}
+	if c.RedisUrl == "" {
+		c.RedisUrl = DefaultRedisUrl
+	}
+	if c.Timezone == "" {
+		c.Timezone = "UTC"
+	}
+	if c.DateFormat == "" {
+		c.DateFormat = TimeFormat
+	}
+	if c.LogLevel == "" {
+		c.LogLevel = "info"
+	}
+	if c.LogFormat == "" {
+		c.LogFormat = "text"
+	}
+	if c.OpenBetReveal == "" {
+		c.OpenBetReveal = RevealParticipants.String()
+	}
+}
+
+// Validate checks every field and returns all problems in one error.
+func (c *Conf) Validate() error {
+	var problems []string
+	required := map[string]string{"postToken": c.PostToken, "slashCommandToken": c.SlashCommandToken, "channel": c.Channel}
+	for _, key := range []string{"postToken", "slashCommandToken", "channel"} {
+		if required[key] == "" {
+			problems = append(problems, key+" is required, set it in conf or "+EnvName(key))
+		}
+	}
+	if len(c.Admins) == 0 {
+		problems = append(problems, "admins should have at least one user, nobody can manage the bot otherwise")
+	}
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		problems = append(problems, "port "+c.Port+" is not a valid port")
+	}
+	if _, err := time.LoadLocation(c.Timezone); err != nil {
+		problems = append(problems, "timezone "+c.Timezone+" is not a valid IANA timezone")
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		problems = append(problems, "logLevel "+c.LogLevel+" should be one of debug, info, warn and error")
+	}
+	if c.LogFormat != "text" && c.LogFormat != "json" {
+		problems = append(problems, "logFormat "+c.LogFormat+" should be text or json")
+	}
+	var commands []string
+	for command := range c.Permissions {
+		commands = append(commands, command)
+	}
+	sort.Strings(commands)
+	for _, command := range commands {
+		if _, err := ParseRole(c.Permissions[command]); err != nil {
+			problems = append(problems, "permission of "+command+" should be one of "+strings.Join(Roles[:], ", ")+", it is "+c.Permissions[command])
+		}
+	}
+	var keys []string
+	for key := range c.APIKeys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if c.APIKeys[key] == "" {
+			problems = append(problems, "apiKeys should map every key to a user")
+		} else if len(key) < minAPIKeyLength {
+			problems = append(problems, "API key of "+c.APIKeys[key]+" should be at least "+strconv.Itoa(minAPIKeyLength)+" characters")
+		}
+	}
+	if c.DashboardSecret != "" && len(c.DashboardSecret) < minAPIKeyLength {
+		problems = append(problems, "dashboardSecret should be at least "+strconv.Itoa(minAPIKeyLength)+" characters")
+	}
+	if c.UploadCharts && c.ChannelID == "" {
+		problems = append(problems, "uploadCharts needs channelId")
+	}
+	if reveal, err := ParseReveal(c.OpenBetReveal); c.OpenBetReveal != "" && (err != nil || reveal == RevealAll) {
+		problems = append(problems, "openBetReveal "+c.OpenBetReveal+" should be one of participants, count and nothing")
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return errors.New("invalid conf: " + strings.Join(problems, "; ") + ".")
+}
+
+// RedisAddress returns the host:port of RedisUrl, its scheme is ignored.
+func (c *Conf) RedisAddress() string {
+	if i := strings.Index(c.RedisUrl, "://"); i != -1 {
+		return c.RedisUrl[i+len("://"):]
+	}
+	return c.RedisUrl
+}
+
+// Warnings returns the problems of the conf that don't stop the bot from starting.
+func (c *Conf) Warnings() []string {
+	var warnings []string
+	if u, err := url.Parse(c.RedisUrl); err == nil && strings.Contains(c.RedisUrl, "://") && u.Scheme != "redis" {
+		warnings = append(warnings, "redisUrl "+c.RedisUrl+" is not a Redis address, its scheme is ignored, use host:port or redis://host:port")
+	}
+	return warnings
+}
+
+// RestartKeys are the JSON keys of fields that only take effect when the bot is restarted.
+var RestartKeys = []string{"redisUrl", "port", "timezone", "cacheBets", "logLevel", "logFormat"}
+
+// KeepRestartKeys sets the fields of RestartKeys to their values in old, since their changes only take effect after a restart.
+func (c *Conf) KeepRestartKeys(old *Conf) {
+	value, oldValue := reflect.ValueOf(c).Elem(), reflect.ValueOf(old).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		if slices.Contains(RestartKeys, strings.Split(value.Type().Field(i).Tag.Get("json"), ",")[0]) {
+			value.Field(i).Set(oldValue.Field(i))
+		}
+	}
+}
+
+// Changes returns the JSON keys of the fields that are different in c than in old, in the order of fields.
+func (c *Conf) Changes(old *Conf) []string {
+	var changes []string
+	value, oldValue := reflect.ValueOf(c).Elem(), reflect.ValueOf(old).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		if !reflect.DeepEqual(value.Field(i).Interface(), oldValue.Field(i).Interface()) {
+			changes = append(changes, strings.Split(value.Type().Field(i).Tag.Get("json"), ",")[0])
+		}
+	}
+	return changes
+}
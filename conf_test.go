@@ -0,0 +1,127 @@
+package slackbet
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApplyEnv(t *testing.T) {
+	env := map[string]string{
+		"SLACKBET_CHANNEL_ID":  "C123",
+		"SLACKBET_CACHE_BETS":  "true",
+		"SLACKBET_PERMISSIONS": "start=moderator, end=moderator",
+		"SLACKBET_ADMINS":      "tarik,,omer",
+	}
+	c := &Conf{ChannelID: "C9NMN9WVP", Admins: []string{"sezgin"}}
+	err := c.ApplyEnv(func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	})
+	if err != nil || c.ChannelID != "C123" || !c.CacheBets || !reflect.DeepEqual(c.Admins, []string{"tarik", "omer"}) ||
+		!reflect.DeepEqual(c.Permissions, map[string]string{"start": "moderator", "end": "moderator"}) {
+		t.Fatal("env is not applied", err, c)
+	}
+	env = map[string]string{"SLACKBET_CACHE_BETS": "yes"}
+	err = c.ApplyEnv(func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	})
+	if err == nil || err.Error() != "SLACKBET_CACHE_BETS should be true or false, it is yes." {
+		t.Fatal("env should be invalid", err)
+	}
+}
+
+func TestValidateConf(t *testing.T) {
+	c := &Conf{PostToken: "token", SlashCommandToken: "token", Channel: "#general", Admins: []string{"tarik"}}
+	c.SetDefaults()
+	if err := c.Validate(); err != nil || c.Port != DefaultPort || c.RedisUrl != DefaultRedisUrl || c.DateFormat != TimeFormat {
+		t.Fatal("conf should be valid with defaults", err, c)
+	}
+	c = &Conf{Port: "http", RedisUrl: "http://localhost:6379", Timezone: "Mars/Olympus", LogFormat: "xml",
+		Permissions: map[string]string{"start": "king", "end": "admin"}}
+	c.SetDefaults()
+	err := c.Validate()
+	expected := "invalid conf: postToken is required, set it in conf or SLACKBET_POST_TOKEN; " +
+		"slashCommandToken is required, set it in conf or SLACKBET_SLASH_COMMAND_TOKEN; " +
+		"channel is required, set it in conf or SLACKBET_CHANNEL; " +
+		"admins should have at least one user, nobody can manage the bot otherwise; " +
+		"port http is not a valid port; " +
+		"timezone Mars/Olympus is not a valid IANA timezone; " +
+		"logFormat xml should be text or json; " +
+		"permission of start should be one of player, moderator, admin, owner, it is king."
+	if err == nil || err.Error() != expected {
+		t.Fatal("conf should be invalid", err)
+	}
+	if warnings := c.Warnings(); len(warnings) != 1 || warnings[0] != "redisUrl http://localhost:6379 is not a Redis address, its scheme is ignored, use host:port or redis://host:port" {
+		t.Fatal("scheme of redisUrl should be warned about", warnings)
+	}
+	if address := c.RedisAddress(); address != "localhost:6379" {
+		t.Fatal("scheme of redisUrl should be ignored", address)
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	for key, expected := range map[string]string{"channelId": "SLACKBET_CHANNEL_ID", "port": "SLACKBET_PORT", "slashCommandToken": "SLACKBET_SLASH_COMMAND_TOKEN"} {
+		if name := EnvName(key); name != expected {
+			t.Fatal("env name is wrong", key, name)
+		}
+	}
+}
+
+func TestConfChanges(t *testing.T) {
+	old := &Conf{Admins: []string{"tarik"}, Channel: "#general", Port: "8080"}
+	c := &Conf{Admins: []string{"tarik", "omer"}, Channel: "#general", Port: "9090"}
+	if changes := c.Changes(old); !reflect.DeepEqual(changes, []string{"admins", "port"}) {
+		t.Fatal("changes are wrong", changes)
+	}
+	if changes := old.Changes(old); changes != nil {
+		t.Fatal("conf should not be changed", changes)
+	}
+}
+
+func TestValidateAPIKeys(t *testing.T) {
+	c := &Conf{PostToken: "token", SlashCommandToken: "token", Channel: "#general", Admins: []string{"tarik"},
+		APIKeys: map[string]string{"short": "tarik", "dashboard-0123456789": ""}}
+	c.SetDefaults()
+	err := c.Validate()
+	if err == nil || err.Error() != "invalid conf: apiKeys should map every key to a user; API key of tarik should be at least 16 characters." {
+		t.Fatal("api keys should be invalid", err)
+	}
+}
+
+func TestValidateDashboardSecret(t *testing.T) {
+	c := &Conf{PostToken: "token", SlashCommandToken: "token", Channel: "#general", Admins: []string{"tarik"}, DashboardSecret: "secret"}
+	c.SetDefaults()
+	err := c.Validate()
+	if err == nil || err.Error() != "invalid conf: dashboardSecret should be at least 16 characters." {
+		t.Fatal("dashboard secret should be invalid", err)
+	}
+	c.DashboardSecret = "secret-0123456789"
+	if err = c.Validate(); err != nil {
+		t.Fatal("dashboard secret should be valid", err)
+	}
+}
+
+func TestValidateUploadCharts(t *testing.T) {
+	c := &Conf{PostToken: "token", SlashCommandToken: "token", Channel: "#general", Admins: []string{"tarik"}, UploadCharts: true}
+	c.SetDefaults()
+	err := c.Validate()
+	if err == nil || err.Error() != "invalid conf: uploadCharts needs channelId." {
+		t.Fatal("upload charts should be invalid", err)
+	}
+}
+
+func TestValidateOpenBetReveal(t *testing.T) {
+	c := &Conf{PostToken: "token", SlashCommandToken: "token", Channel: "#general", Admins: []string{"tarik"}}
+	c.SetDefaults()
+	if err := c.Validate(); err != nil || c.OpenBetReveal != "participants" {
+		t.Fatal("open bet reveal should default to participants", c.OpenBetReveal, err)
+	}
+	for _, reveal := range []string{"all", "numbers"} {
+		c.OpenBetReveal = reveal
+		err := c.Validate()
+		if err == nil || err.Error() != "invalid conf: openBetReveal "+reveal+" should be one of participants, count and nothing." {
+			t.Fatal("open bet reveal should be invalid", reveal, err)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package format
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/mtyurt/slackbet"
+)
+
+// Block is a Slack Block Kit block, see https://api.slack.com/block-kit.
+type Block struct {
+	Type     string       `json:"type"`
+	Text     *TextObject  `json:"text,omitempty"`
+	Fields   []TextObject `json:"fields,omitempty"`
+	Elements []TextObject `json:"elements,omitempty"`
+}
+
+// TextObject is a plain_text or mrkdwn text of a block.
+type TextObject struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// Message is a Slack message with blocks, Text is shown in notifications and by clients that cannot show the blocks.
+type Message struct {
+	Text   string  `json:"text"`
+	Blocks []Block `json:"blocks"`
+}
+
+// Message renders the result as a Slack message with its Slack text and blocks.
+func (f *Formatter) Message(result slackbet.Result) Message {
+	return Message{Text: f.Text(result), Blocks: f.Blocks(result)}
+}
+
+// Blocks renders the result as Block Kit blocks. A bet has a header, its dates and winner score as fields,
+// and its entries, other results are a section of their Slack text.
+func (f *Formatter) Blocks(result slackbet.Result) []Block {
+	bet, ok := result.(*slackbet.BetInfo)
+	if !ok || bet == nil {
+		return []Block{section(f.Text(result))}
+	}
+	blocks := []Block{{Type: "header", Text: &TextObject{Type: "plain_text", Text: "Bet #" + strconv.Itoa(bet.ID)}}}
+	fields := []TextObject{
+		{Type: "mrkdwn", Text: "*Status*\n" + bet.Status},
+		{Type: "mrkdwn", Text: "*Started*\n" + f.Date(bet.StartDate)},
+	}
+	if !bet.EndDate.IsZero() {
+		fields = append(fields, TextObject{Type: "mrkdwn", Text: "*Ended*\n" + f.Date(bet.EndDate)})
+	}
+	if bet.WinnerScore != -1 {
+		fields = append(fields, TextObject{Type: "mrkdwn", Text: "*Winner score*\n" + strconv.Itoa(bet.WinnerScore)})
+	}
+	if bet.Visibility != "" {
+		fields = append(fields, TextObject{Type: "mrkdwn", Text: "*Visibility*\n" + bet.Visibility})
+	}
+	if bet.Sealed {
+		fields = append(fields, TextObject{Type: "mrkdwn", Text: "*Sealed*\nyes"})
+	}
+	blocks = append(blocks, Block{Type: "section", Fields: fields})
+	if bet.Entries == nil {
+		hidden := "Guesses are hidden until the bet ends."
+		if bet.EntryCount != -1 {
+			hidden = strconv.Itoa(bet.EntryCount) + " guesses, they are hidden until the bet ends."
+		}
+		return append(blocks, Block{Type: "context", Elements: []TextObject{{Type: "mrkdwn", Text: hidden}}})
+	}
+	entries := ""
+	for i, entry := range bet.Entries {
+		line := strconv.Itoa(i+1) + ". " + entry.User + " " + strconv.Itoa(entry.Number)
+		if entry.ExtraInfo != "" {
+			line += " " + entry.ExtraInfo
+		}
+		if entry.Winner {
+			line = "*" + line + "* :trophy:"
+		}
+		entries += line + "\n"
+	}
+	if entries == "" {
+		entries = "Nobody placed a bet."
+	}
+	blocks = append(blocks, Block{Type: "divider"}, section(entries))
+	if len(bet.Disqualified) > 0 {
+		disqualified := "Disqualified: " + strings.Join(bet.Disqualified, ", ")
+		blocks = append(blocks, Block{Type: "context", Elements: []TextObject{{Type: "mrkdwn", Text: disqualified}}})
+	}
+	return blocks
+}
+
+func section(text string) Block {
+	return Block{Type: "section", Text: &TextObject{Type: "mrkdwn", Text: text}}
+}
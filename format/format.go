@@ -0,0 +1,268 @@
+// Package format renders results of slackbet.BetService as Slack text, Block Kit blocks,
+// JSON API objects and command line tables.
+package format
+
+import (
+	"bytes"
+	"encoding/csv"
+	"math"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mtyurt/slackbet"
+	"github.com/mtyurt/slackbet/repo"
+)
+
+// timeFormat is the layout of times in the audit log and the notification list.
+const timeFormat = "02-01-2006 15:04"
+
+// notificationTextLimit is the number of characters of a notification shown in the list.
+const notificationTextLimit = 40
+
+// Formatter renders results with dates in Location, formatted with Layout.
+type Formatter struct {
+	Layout   string
+	Location *time.Location
+}
+
+// New returns a Formatter in the team's timezone and date format.
+func New(conf *slackbet.Conf) *Formatter {
+	return &Formatter{Layout: conf.DateLayout(), Location: conf.Location()}
+}
+
+// Text renders the result as a Slack message, winners are in bold.
+func (f *Formatter) Text(result slackbet.Result) string {
+	return f.text(result, true)
+}
+
+// Table renders the result for a terminal, columns are aligned and there is no Slack markup.
+func (f *Formatter) Table(result slackbet.Result) string {
+	var buf bytes.Buffer
+	table := tabwriter.NewWriter(&buf, 0, 8, 2, ' ', 0)
+	table.Write([]byte(f.text(result, false)))
+	table.Flush()
+	return buf.String()
+}
+
+func (f *Formatter) text(result slackbet.Result, markup bool) string {
+	switch r := result.(type) {
+	case *slackbet.Confirmation:
+		return confirmationText(r)
+	case *slackbet.BetInfo:
+		if r == nil {
+			return "No bet exists"
+		}
+		return f.betText(r, markup)
+	case *slackbet.BetList:
+		response := ""
+		for _, bet := range r.Bets {
+			response += f.summary(&bet) + "\n"
+		}
+		if r.More {
+			response += "page " + strconv.Itoa(r.Page) + ", older bets are on page " + strconv.Itoa(r.Page+1) + "\n"
+		} else if r.Page > 1 {
+			response += "page " + strconv.Itoa(r.Page) + ", the last page\n"
+		}
+		return response
+	case *slackbet.WinnerReport:
+		return winnerReportText(r)
+	case *slackbet.AbsentList:
+		if r.BetID == -1 {
+			return "there is no active bet."
+		}
+		return "Users who have not placed a bet yet: " + strings.Join(r.Users, ", ")
+	case *slackbet.RoleList:
+		response := ""
+		for role := slackbet.RoleOwner; role > slackbet.RolePlayer; role-- {
+			response += role.String() + ": " + strings.Join(r.Users[role], ", ") + "\n"
+		}
+		return response
+	case *slackbet.AuditLog:
+		return f.auditLogText(r)
+	case *slackbet.AuditExport:
+		return auditCSV(r.Entries)
+	case *slackbet.NotificationList:
+		return f.notificationsText(r)
+	case *slackbet.Leaderboard:
+		return leaderboardText(r)
+	case *slackbet.GuessStats:
+		return statsText(r)
+	}
+	return ""
+}
+
+// Date formats t in the team's timezone and date format.
+func (f *Formatter) Date(t time.Time) string {
+	return t.In(f.Location).Format(f.Layout)
+}
+
+// summary formats the bet in one line, like "3\tstart: 01-03-2016\t(still open)".
+func (f *Formatter) summary(bet *slackbet.BetInfo) string {
+	summary := repo.BetSummary{ID: bet.ID, Status: bet.Status, StartDate: bet.StartDate, EndDate: bet.EndDate,
+		WinnerNumber: bet.WinnerScore, Visibility: bet.Visibility, Sealed: bet.Sealed}
+	return summary.Format(f.Layout, f.Location)
+}
+
+// betText formats the bet with its entries, an open bet is only its summary.
+func (f *Formatter) betText(bet *slackbet.BetInfo, markup bool) string {
+	if bet.Entries == nil {
+		return f.summary(bet)
+	}
+	response := f.summary(bet) + "\n\n"
+	for i, entry := range bet.Entries {
+		line := strconv.Itoa(i+1) + ".\t" + entry.User + "\t" + strconv.Itoa(entry.Number)
+		if entry.ExtraInfo != "" {
+			line += "\t" + entry.ExtraInfo
+		}
+		if entry.Winner && markup {
+			line = "*" + line + " (WINNER!)*"
+		} else if entry.Winner {
+			line += "\t(WINNER!)"
+		}
+		response += line + "\n"
+	}
+	if len(bet.Disqualified) > 0 {
+		response += "disqualified: " + strings.Join(bet.Disqualified, ", ") + "\n"
+	}
+	return response
+}
+
+func confirmationText(c *slackbet.Confirmation) string {
+	bet := "bet[" + strconv.Itoa(c.BetID) + "]"
+	switch c.Action {
+	case "start":
+		return "started " + bet + " successfully"
+	case "end":
+		return "ended " + bet + " successfully"
+	case "save", "savefor":
+		return "saved successfully"
+	case "seal":
+		if c.Salt == "" {
+			return "sealed your guess for " + bet + ", reveal it after the bet ends with /bet reveal <number> <salt>"
+		}
+		return "sealed your guess for " + bet + ", keep this to reveal it after the bet ends: /bet reveal " + c.Value + " " + c.Salt
+	case "reveal":
+		return "revealed your guess " + c.Value + " for " + bet + " successfully"
+	case "savewinner":
+		return "winner " + c.Value + " for bet " + strconv.Itoa(c.BetID) + " is saved successfully"
+	case "clearwinner":
+		return "winner of " + bet + " is cleared successfully"
+	case "role":
+		return c.User + " is " + c.Value + " now."
+	case "unsave":
+		return "removed bet of " + c.User + " successfully"
+	case "reopen", "delete", "archive", "restore", "purge":
+		return pastTense(c.Action) + " " + bet + " successfully"
+	case "undo":
+		return "reverted #" + strconv.Itoa(c.Reverted.ID) + " " + c.Reverted.Action + " by " + c.Reverted.Actor + " successfully"
+	case "replay":
+		if c.Count == 0 {
+			return "there are no failed notifications."
+		}
+		return "queued " + strconv.Itoa(c.Count) + " notifications again"
+	}
+	return c.Action + " is done successfully"
+}
+
+func pastTense(action string) string {
+	if strings.HasSuffix(action, "e") {
+		return action + "d"
+	}
+	return action + "ed"
+}
+
+func winnerReportText(r *slackbet.WinnerReport) string {
+	if r.BetID == -1 {
+		return "No bet exists"
+	}
+	if r.Open {
+		return "you cannot query who wins for an active bet! I'm telling mom"
+	}
+	response := "bet " + strconv.Itoa(r.BetID) + ", " + strconv.Itoa(r.Participants) + " people joined, hypothetical " +
+		strconv.Itoa(len(r.Winners)) + " winners for score " + strconv.Itoa(r.Reference) + ": \n"
+	for _, winner := range r.Winners {
+		response += "\t" + winner.User + "\t" + strconv.Itoa(winner.Number) + "\n"
+	}
+	return response
+}
+
+func leaderboardText(leaderboard *slackbet.Leaderboard) string {
+	if len(leaderboard.Players) == 0 {
+		return "there are no bets with a winner score yet."
+	}
+	response := ""
+	for i, player := range leaderboard.Players {
+		response += strconv.Itoa(i+1) + ".\t" + player.User + "\t" + strconv.Itoa(player.Wins) + " wins\t" + strconv.Itoa(player.Bets) + " bets\n"
+	}
+	return response
+}
+
+// statsText formats the stats in a line, followed by the error of the median guess if the winner score is saved.
+func statsText(stats *slackbet.GuessStats) string {
+	response := strconv.Itoa(stats.Count) + " guesses, min " + strconv.Itoa(stats.Min) + ", max " + strconv.Itoa(stats.Max) +
+		", mean " + decimal(stats.Mean) + ", median " + decimal(stats.Median) + ", std dev " + decimal(stats.StdDev)
+	if stats.WinnerScore != -1 {
+		response += "\nthe median guess was " + decimal(stats.MedianError) + " off the winner score " + strconv.Itoa(stats.WinnerScore)
+	}
+	return response
+}
+
+// decimal formats v with at most one decimal, like "149" or "71.1".
+func decimal(v float64) string {
+	return strconv.FormatFloat(math.Round(v*10)/10, 'f', -1, 64)
+}
+
+func (f *Formatter) auditLogText(log *slackbet.AuditLog) string {
+	if len(log.Entries) == 0 {
+		return "audit log is empty."
+	}
+	response := ""
+	for _, entry := range log.Entries {
+		line := "#" + strconv.Itoa(entry.ID) + "\t" + entry.Timestamp.In(f.Location).Format(timeFormat) + "\t" + entry.Actor + "\t" + entry.Action
+		if entry.BetID != 0 {
+			line += "\tbet " + strconv.Itoa(entry.BetID)
+		}
+		if entry.Target != "" {
+			line += "\t" + entry.Target
+		}
+		if entry.OldValue != "" || entry.NewValue != "" {
+			line += "\t" + entry.OldValue + " -> " + entry.NewValue
+		}
+		if entry.Reverts != 0 {
+			line += "\treverts #" + strconv.Itoa(entry.Reverts)
+		}
+		response += line + "\n"
+	}
+	return response
+}
+
+// auditCSV renders the entries as CSV with a header, timestamps are in RFC 3339.
+func auditCSV(entries []repo.AuditEntry) string {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"id", "timestamp", "actor", "action", "betId", "target", "oldValue", "newValue", "reverts"})
+	for _, entry := range entries {
+		w.Write([]string{strconv.Itoa(entry.ID), entry.Timestamp.Format(time.RFC3339), entry.Actor, entry.Action,
+			strconv.Itoa(entry.BetID), entry.Target, entry.OldValue, entry.NewValue, strconv.Itoa(entry.Reverts)})
+	}
+	w.Flush()
+	return buf.String()
+}
+
+func (f *Formatter) notificationsText(list *slackbet.NotificationList) string {
+	if len(list.Notifications) == 0 {
+		return "there are no failed notifications."
+	}
+	response := ""
+	for _, notification := range list.Notifications {
+		text := []rune(notification.Text)
+		if len(text) > notificationTextLimit {
+			text = append(text[:notificationTextLimit], []rune("...")...)
+		}
+		response += "#" + strconv.Itoa(notification.ID) + "\t" + notification.CreatedAt.In(f.Location).Format(timeFormat) +
+			"\t" + notification.Channel + "\t" + strconv.Itoa(notification.Attempts) + " attempts\t" + notification.LastError + "\t" + string(text) + "\n"
+	}
+	return response
+}
@@ -0,0 +1,139 @@
+package format
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mtyurt/slackbet"
+	"github.com/mtyurt/slackbet/repo"
+)
+
+func closedBet() *slackbet.BetInfo {
+	return &slackbet.BetInfo{ID: 2, Status: "closed", StartDate: time.Date(2016, 2, 1, 0, 0, 0, 0, time.UTC),
+		EndDate: time.Date(2016, 2, 2, 0, 0, 0, 0, time.UTC), WinnerScore: 90, EntryCount: 2,
+		Entries: []slackbet.Entry{{User: "tarik", Number: 75, ExtraInfo: "lucky"}, {User: "omer", Number: 100, Winner: true}}}
+}
+
+func openBet() *slackbet.BetInfo {
+	return &slackbet.BetInfo{ID: 3, Status: "open", StartDate: time.Date(2016, 3, 1, 0, 0, 0, 0, time.UTC), WinnerScore: -1, EntryCount: 4}
+}
+
+func formatter() *Formatter {
+	return &Formatter{Layout: "02-01-2006", Location: time.UTC}
+}
+
+func TestText(t *testing.T) {
+	f := formatter()
+	tests := []struct {
+		result   slackbet.Result
+		expected string
+	}{
+		{&slackbet.Confirmation{Action: "start", BetID: 1}, "started bet[1] successfully"},
+		{&slackbet.Confirmation{Action: "savewinner", BetID: 1, Value: "120"}, "winner 120 for bet 1 is saved successfully"},
+		{&slackbet.Confirmation{Action: "archive", BetID: 4}, "archived bet[4] successfully"},
+		{&slackbet.Confirmation{Action: "undo", Reverted: &repo.AuditEntry{ID: 7, Action: "end", Actor: "sezgin"}}, "reverted #7 end by sezgin successfully"},
+		{&slackbet.Confirmation{Action: "role", User: "omer", Value: "admin"}, "omer is admin now."},
+		{&slackbet.Confirmation{Action: "seal", BetID: 1, Value: "100", Salt: "c0ffee"},
+			"sealed your guess for bet[1], keep this to reveal it after the bet ends: /bet reveal 100 c0ffee"},
+		{&slackbet.Confirmation{Action: "reveal", BetID: 1, Value: "100"}, "revealed your guess 100 for bet[1] successfully"},
+		{&slackbet.BetInfo{ID: 4, Status: "closed", StartDate: time.Date(2016, 4, 1, 0, 0, 0, 0, time.UTC), WinnerScore: -1, Sealed: true,
+			Entries: []slackbet.Entry{{User: "omer", Number: 100}}, Disqualified: []string{"ali"}},
+			"4\tstart: 01-04-2016\t(sealed)\n\n1.\tomer\t100\ndisqualified: ali\n"},
+		{closedBet(), "2\tstart: 01-02-2016\tend: 02-02-2016\twinner score: 90\n\n1.\ttarik\t75\tlucky\n*2.\tomer\t100 (WINNER!)*\n"},
+		{openBet(), "3\tstart: 01-03-2016\t(still open)"},
+		{(*slackbet.BetInfo)(nil), "No bet exists"},
+		{&slackbet.BetList{Bets: []slackbet.BetInfo{*openBet()}, Page: 1, More: true}, "3\tstart: 01-03-2016\t(still open)\npage 1, older bets are on page 2\n"},
+		{&slackbet.BetList{Bets: []slackbet.BetInfo{*openBet()}, Page: 2}, "3\tstart: 01-03-2016\t(still open)\npage 2, the last page\n"},
+		{&slackbet.WinnerReport{BetID: 2, Participants: 2, Reference: 80, Winners: []slackbet.Entry{{User: "tarik", Number: 75}}},
+			"bet 2, 2 people joined, hypothetical 1 winners for score 80: \n\ttarik\t75\n"},
+		{&slackbet.WinnerReport{BetID: 3, Open: true}, "you cannot query who wins for an active bet! I'm telling mom"},
+		{&slackbet.AbsentList{BetID: 3, Users: []string{"user6", "user7"}}, "Users who have not placed a bet yet: user6, user7"},
+		{&slackbet.AbsentList{BetID: -1}, "there is no active bet."},
+		{&slackbet.RoleList{Users: map[slackbet.Role][]string{slackbet.RoleOwner: {"sezgin"}}}, "owner: sezgin\nadmin: \nmoderator: \n"},
+		{&slackbet.AuditLog{}, "audit log is empty."},
+		{&slackbet.NotificationList{}, "there are no failed notifications."},
+		{&slackbet.GuessStats{Count: 5, Min: 75, Max: 275, Mean: 149, Median: 120, StdDev: 71.0915, WinnerScore: -1},
+			"5 guesses, min 75, max 275, mean 149, median 120, std dev 71.1"},
+		{&slackbet.GuessStats{Count: 2, Min: 75, Max: 100, Mean: 87.5, Median: 87.5, StdDev: 12.5, WinnerScore: 90, MedianError: 2.5},
+			"2 guesses, min 75, max 100, mean 87.5, median 87.5, std dev 12.5\nthe median guess was 2.5 off the winner score 90"},
+		{&slackbet.Leaderboard{Bets: 2, Players: []slackbet.Player{{User: "omer", Wins: 2, Bets: 2}, {User: "tarik", Bets: 1}}},
+			"1.\tomer\t2 wins\t2 bets\n2.\ttarik\t0 wins\t1 bets\n"},
+	}
+	for _, test := range tests {
+		if text := f.Text(test.result); text != test.expected {
+			t.Errorf("text of %+v is wrong, expected %q but was %q", test.result, test.expected, text)
+		}
+	}
+}
+
+func TestTable(t *testing.T) {
+	table := formatter().Table(closedBet())
+	expected := "2  start: 01-02-2016  end: 02-02-2016  winner score: 90\n\n1.  tarik  75   lucky\n2.  omer   100  (WINNER!)\n"
+	if table != expected {
+		t.Fatalf("table is wrong, expected\n%q\nbut was\n%q", expected, table)
+	}
+}
+
+func TestAuditCSV(t *testing.T) {
+	entries := []repo.AuditEntry{{ID: 4, Timestamp: time.Date(2016, 2, 1, 10, 0, 0, 0, time.UTC), Actor: "sezgin", Action: "savefor", BetID: 1, Target: "tarik", NewValue: "90"}}
+	csv := formatter().Text(&slackbet.AuditExport{Entries: entries})
+	expected := "id,timestamp,actor,action,betId,target,oldValue,newValue,reverts\n4,2016-02-01T10:00:00Z,sezgin,savefor,1,tarik,,90,0\n"
+	if csv != expected {
+		t.Fatalf("csv is wrong, expected %q but was %q", expected, csv)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	f := formatter()
+	bet := f.JSON(closedBet()).(Bet)
+	if bet.ID != 2 || *bet.WinnerScore != 90 || *bet.EntryCount != 2 || bet.EndDate == nil || len(bet.Entries) != 2 || !bet.Entries[1].Winner {
+		t.Fatalf("closed bet is wrong %+v", bet)
+	}
+	bet = f.JSON(openBet()).(Bet)
+	if bet.WinnerScore != nil || bet.EndDate != nil || bet.Entries != nil || *bet.EntryCount != 4 {
+		t.Fatalf("open bet is wrong %+v", bet)
+	}
+	page := f.JSON(&slackbet.BetList{Bets: []slackbet.BetInfo{*closedBet()}, Page: 1}).(BetPage)
+	if len(page.Bets) != 1 || page.Bets[0].EntryCount != nil {
+		t.Fatalf("bet page is wrong %+v", page)
+	}
+	confirmation := f.JSON(&slackbet.Confirmation{Action: "undo", Reverted: &repo.AuditEntry{ID: 7, Action: "end", Actor: "sezgin"}}).(Confirmation)
+	if *confirmation.Reverted != 7 || confirmation.Message != "reverted #7 end by sezgin successfully" {
+		t.Fatalf("confirmation is wrong %+v", confirmation)
+	}
+	encoded, err := json.Marshal(f.JSON(&slackbet.AbsentList{BetID: -1}))
+	if err != nil || string(encoded) != `{"betId":-1,"users":[]}` {
+		t.Fatal("absent list is wrong", err, string(encoded))
+	}
+	roles := f.JSON(&slackbet.RoleList{Users: map[slackbet.Role][]string{slackbet.RoleAdmin: {"tarik"}}})
+	if !reflect.DeepEqual(roles, map[string][]string{"owner": {}, "admin": {"tarik"}, "moderator": {}}) {
+		t.Fatalf("roles are wrong %+v", roles)
+	}
+	if f.JSON((*slackbet.BetInfo)(nil)) != nil {
+		t.Fatal("nil bet should be null")
+	}
+}
+
+func TestBlocks(t *testing.T) {
+	f := formatter()
+	blocks := f.Blocks(closedBet())
+	if len(blocks) != 4 || blocks[0].Type != "header" || blocks[0].Text.Text != "Bet #2" || blocks[2].Type != "divider" {
+		t.Fatalf("blocks are wrong %+v", blocks)
+	}
+	if len(blocks[1].Fields) != 4 || blocks[1].Fields[3].Text != "*Winner score*\n90" {
+		t.Fatalf("fields are wrong %+v", blocks[1].Fields)
+	}
+	if blocks[3].Text.Text != "1. tarik 75 lucky\n*2. omer 100* :trophy:\n" {
+		t.Fatalf("entries are wrong %q", blocks[3].Text.Text)
+	}
+	blocks = f.Blocks(openBet())
+	if len(blocks) != 3 || blocks[2].Type != "context" || blocks[2].Elements[0].Text != "4 guesses, they are hidden until the bet ends." {
+		t.Fatalf("open bet blocks are wrong %+v", blocks)
+	}
+	blocks = f.Blocks(&slackbet.Confirmation{Action: "end", BetID: 3})
+	if len(blocks) != 1 || blocks[0].Type != "section" || blocks[0].Text.Text != "ended bet[3] successfully" {
+		t.Fatalf("confirmation blocks are wrong %+v", blocks)
+	}
+}
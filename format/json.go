@@ -0,0 +1,181 @@
+package format
+
+import (
+	"time"
+
+	"github.com/mtyurt/slackbet"
+	"github.com/mtyurt/slackbet/repo"
+)
+
+// Bet is a bet in the JSON API.
+type Bet struct {
+	ID          int        `json:"id"`
+	Status      string     `json:"status"`
+	StartDate   time.Time  `json:"startDate"`
+	EndDate     *time.Time `json:"endDate,omitempty"`
+	WinnerScore *int       `json:"winnerScore"`
+	Visibility  string     `json:"visibility,omitempty"`
+	// EntryCount is not known in lists and omitted if it is hidden.
+	EntryCount *int    `json:"entryCount,omitempty"`
+	Entries    []Entry `json:"entries,omitempty"`
+	Sealed     bool    `json:"sealed,omitempty"`
+	// Disqualified are the users whose sealed guesses don't count.
+	Disqualified []string `json:"disqualified,omitempty"`
+}
+
+// Entry is a guess of a user in the JSON API.
+type Entry struct {
+	User      string `json:"user"`
+	Number    int    `json:"number"`
+	ExtraInfo string `json:"extraInfo,omitempty"`
+	Winner    bool   `json:"winner,omitempty"`
+	// Salt is only returned to the user who saves a sealed guess.
+	Salt string `json:"salt,omitempty"`
+}
+
+// BetPage is a page of bets in the JSON API.
+type BetPage struct {
+	Bets []Bet `json:"bets"`
+	Page int   `json:"page"`
+	// More is true if there are older bets on the next page.
+	More bool `json:"more"`
+}
+
+// Confirmation is the result of a command that changes something in the JSON API, Message is its Slack text.
+type Confirmation struct {
+	Action   string `json:"action"`
+	BetID    int    `json:"betId,omitempty"`
+	User     string `json:"user,omitempty"`
+	Value    string `json:"value,omitempty"`
+	Salt     string `json:"salt,omitempty"`
+	Count    int    `json:"count,omitempty"`
+	Reverted *int   `json:"reverted,omitempty"`
+	Message  string `json:"message"`
+}
+
+// WinnerReport is a hypothetical winner list in the JSON API.
+type WinnerReport struct {
+	BetID        int     `json:"betId"`
+	Open         bool    `json:"open,omitempty"`
+	Participants int     `json:"participants"`
+	Reference    int     `json:"reference"`
+	Winners      []Entry `json:"winners"`
+}
+
+// AbsentList is the users who have not placed a bet in the JSON API.
+type AbsentList struct {
+	BetID int      `json:"betId"`
+	Users []string `json:"users"`
+}
+
+// AuditEntry is an entry of the audit log in the JSON API.
+type AuditEntry struct {
+	ID        int       `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Actor     string    `json:"actor"`
+	Action    string    `json:"action"`
+	BetID     int       `json:"betId,omitempty"`
+	Target    string    `json:"target,omitempty"`
+	OldValue  string    `json:"oldValue,omitempty"`
+	NewValue  string    `json:"newValue,omitempty"`
+	Reverts   int       `json:"reverts,omitempty"`
+}
+
+// Notification is a failed callback in the JSON API.
+type Notification struct {
+	ID        int       `json:"id"`
+	Channel   string    `json:"channel"`
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"createdAt"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"lastError"`
+}
+
+// JSON returns the result as a value to be encoded by encoding/json. Roles are a map of role names
+// to users, audit logs and notification lists are arrays. A nil BetInfo is null.
+func (f *Formatter) JSON(result slackbet.Result) interface{} {
+	switch r := result.(type) {
+	case *slackbet.Confirmation:
+		c := Confirmation{Action: r.Action, BetID: r.BetID, User: r.User, Value: r.Value, Salt: r.Salt, Count: r.Count, Message: confirmationText(r)}
+		if r.Reverted != nil {
+			c.Reverted = &r.Reverted.ID
+		}
+		return c
+	case *slackbet.BetInfo:
+		if r == nil {
+			return nil
+		}
+		return BetJSON(r)
+	case *slackbet.BetList:
+		page := BetPage{Bets: []Bet{}, Page: r.Page, More: r.More}
+		for _, bet := range r.Bets {
+			b := BetJSON(&bet)
+			b.EntryCount = nil
+			page.Bets = append(page.Bets, b)
+		}
+		return page
+	case *slackbet.WinnerReport:
+		return WinnerReport{BetID: r.BetID, Open: r.Open, Participants: r.Participants, Reference: r.Reference, Winners: entriesJSON(r.Winners)}
+	case *slackbet.AbsentList:
+		users := r.Users
+		if users == nil {
+			users = []string{}
+		}
+		return AbsentList{BetID: r.BetID, Users: users}
+	case *slackbet.RoleList:
+		roles := make(map[string][]string)
+		for role := slackbet.RoleOwner; role > slackbet.RolePlayer; role-- {
+			roles[role.String()] = append([]string{}, r.Users[role]...)
+		}
+		return roles
+	case *slackbet.AuditLog:
+		return auditJSON(r.Entries)
+	case *slackbet.AuditExport:
+		return auditJSON(r.Entries)
+	case *slackbet.NotificationList:
+		notifications := []Notification{}
+		for _, n := range r.Notifications {
+			notifications = append(notifications, Notification{ID: n.ID, Channel: n.Channel, Text: n.Text, CreatedAt: n.CreatedAt, Attempts: n.Attempts, LastError: n.LastError})
+		}
+		return notifications
+	}
+	return nil
+}
+
+// BetJSON returns the bet in the JSON API, entries are omitted while the bet is open.
+func BetJSON(bet *slackbet.BetInfo) Bet {
+	result := Bet{ID: bet.ID, Status: bet.Status, StartDate: bet.StartDate, Visibility: bet.Visibility, Sealed: bet.Sealed, Disqualified: bet.Disqualified}
+	if bet.EntryCount != -1 {
+		entryCount := bet.EntryCount
+		result.EntryCount = &entryCount
+	}
+	if !bet.EndDate.IsZero() {
+		endDate := bet.EndDate
+		result.EndDate = &endDate
+	}
+	if bet.WinnerScore != -1 {
+		score := bet.WinnerScore
+		result.WinnerScore = &score
+	}
+	if bet.Entries != nil {
+		result.Entries = entriesJSON(bet.Entries)
+	}
+	return result
+}
+
+func entriesJSON(entries []slackbet.Entry) []Entry {
+	result := []Entry{}
+	for _, entry := range entries {
+		result = append(result, Entry{User: entry.User, Number: entry.Number, ExtraInfo: entry.ExtraInfo, Winner: entry.Winner})
+	}
+	return result
+}
+
+func auditJSON(entries []repo.AuditEntry) []AuditEntry {
+	result := []AuditEntry{}
+	for _, e := range entries {
+		result = append(result, AuditEntry{ID: e.ID, Timestamp: e.Timestamp, Actor: e.Actor, Action: e.Action, BetID: e.BetID,
+			Target: e.Target, OldValue: e.OldValue, NewValue: e.NewValue, Reverts: e.Reverts})
+	}
+	return result
+}
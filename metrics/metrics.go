@@ -0,0 +1,134 @@
+// Package metrics collects Prometheus metrics of slash commands, repo operations and Slack callbacks.
+package metrics
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/mtyurt/slackbet/repo"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "slackbet"
+
+// Registry holds every slackbet metric, it is served by Handler.
+var Registry = prometheus.NewRegistry()
+
+var (
+	commandRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "command_requests_total",
+		Help:      "Slash command requests by command and result, result is ok or error.",
+	}, []string{"command", "result"})
+	commandDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "command_duration_seconds",
+		Help:      "Time spent handling slash commands.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"command"})
+	repoDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "repo_operation_duration_seconds",
+		Help:      "Time spent in repo operations.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+	repoErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "repo_operation_errors_total",
+		Help:      "Repo operations that returned an error.",
+	}, []string{"operation"})
+	slackCallbacks = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "slack_callbacks_total",
+		Help:      "Messages posted to Slack by result, result is ok or error.",
+	}, []string{"result"})
+)
+
+func init() {
+	Registry.MustRegister(commandRequests, commandDuration, repoDuration, repoErrors, slackCallbacks)
+}
+
+// Handler serves the metrics in Registry.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// Command wraps a slash command handler to count its requests and time them.
+func Command(command string, handler func(string, []string) (string, error)) func(string, []string) (string, error) {
+	return func(user string, args []string) (string, error) {
+		start := time.Now()
+		response, err := handler(user, args)
+		commandDuration.WithLabelValues(command).Observe(time.Since(start).Seconds())
+		commandRequests.WithLabelValues(command, result(err)).Inc()
+		return response, err
+	}
+}
+
+// SlackCallback counts a message posted to Slack, err is the result of posting.
+func SlackCallback(err error) {
+	slackCallbacks.WithLabelValues(result(err)).Inc()
+}
+
+// RegisterOpenBet registers the participant count gauge of the open bet, participants is called on every scrape
+// and returns 0 if there is no open bet. It returns -1 if the count is hidden, then the gauge is not reported.
+// The server registers it in Registry.
+func RegisterOpenBet(registerer prometheus.Registerer, participants func() (int, error)) {
+	registerer.MustRegister(&openBetCollector{participants: participants, desc: prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "open_bet_participants"),
+		"Number of users who placed a bet in the open bet.", nil, nil)})
+}
+
+// openBetCollector reports the participant count of the open bet unless it is hidden.
+type openBetCollector struct {
+	participants func() (int, error)
+	desc         *prometheus.Desc
+}
+
+func (c *openBetCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *openBetCollector) Collect(ch chan<- prometheus.Metric) {
+	count, err := c.participants()
+	if err != nil {
+		slog.Error("participants of the open bet cannot be counted", "err", err)
+		count = 0
+	}
+	if count == -1 {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(count))
+}
+
+// RegisterCache registers hit and miss counters of a repo.CachedRepo, the server registers them in Registry.
+func RegisterCache(registerer prometheus.Registerer, cache *repo.CachedRepo) {
+	registerer.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_hits_total",
+		Help:      "Bet reads served from the cache.",
+	}, func() float64 {
+		return float64(cache.Stats().Hits)
+	}), prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_misses_total",
+		Help:      "Bet reads that went to the repo.",
+	}, func() float64 {
+		return float64(cache.Stats().Misses)
+	}))
+}
+
+func observeRepo(operation string, start time.Time, err error) {
+	repoDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if err != nil {
+		repoErrors.WithLabelValues(operation).Inc()
+	}
+}
+
+func result(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// scrape returns the metrics served from gatherer.
+func scrape(gatherer prometheus.Gatherer) string {
+	w := httptest.NewRecorder()
+	promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{}).ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(w.Body)
+	return string(body)
+}
+
+func TestCommandMetrics(t *testing.T) {
+	commandRequests.Reset()
+	commandDuration.Reset()
+	handler := Command("start", func(user string, args []string) (string, error) {
+		if user == "omer" {
+			return "", errors.New("You are not authorized to start a bet.")
+		}
+		return "ok", nil
+	})
+	handler("sezgin", []string{"start"})
+	handler("omer", []string{"start"})
+	handler("omer", []string{"start"})
+
+	body := scrape(Registry)
+	for _, line := range []string{
+		`slackbet_command_requests_total{command="start",result="ok"} 1`,
+		`slackbet_command_requests_total{command="start",result="error"} 2`,
+		`slackbet_command_duration_seconds_count{command="start"} 3`,
+	} {
+		if !strings.Contains(body, line) {
+			t.Fatal("metrics should contain", line, "but was\n", body)
+		}
+	}
+}
+
+func TestOpenBetMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	RegisterOpenBet(registry, func() (int, error) { return 3, nil })
+	if body := scrape(registry); !strings.Contains(body, "slackbet_open_bet_participants 3") {
+		t.Fatal("participants should be reported", body)
+	}
+}
+
+func TestHiddenOpenBet(t *testing.T) {
+	collector := &openBetCollector{participants: func() (int, error) { return -1, nil }}
+	ch := make(chan prometheus.Metric, 1)
+	collector.Collect(ch)
+	if len(ch) != 0 {
+		t.Fatal("hidden count should not be reported")
+	}
+}
@@ -0,0 +1,229 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/mtyurt/slackbet/repo"
+)
+
+// Repo is a repo.Repo that times every operation of the underlying repo and counts its errors.
+type Repo struct {
+	Repo repo.Repo
+}
+
+func (r *Repo) AddNewBet(betID int, startDate time.Time, sealed bool) error {
+	start := time.Now()
+	err := r.Repo.AddNewBet(betID, startDate, sealed)
+	observeRepo("AddNewBet", start, err)
+	return err
+}
+
+func (r *Repo) BetIDExists(betID int) (bool, error) {
+	start := time.Now()
+	value, err := r.Repo.BetIDExists(betID)
+	observeRepo("BetIDExists", start, err)
+	return value, err
+}
+
+func (r *Repo) GetBetDetails(betID int) ([]repo.BetDetail, error) {
+	start := time.Now()
+	value, err := r.Repo.GetBetDetails(betID)
+	observeRepo("GetBetDetails", start, err)
+	return value, err
+}
+
+func (r *Repo) GetIDOfOpenBet() (int, error) {
+	start := time.Now()
+	value, err := r.Repo.GetIDOfOpenBet()
+	observeRepo("GetIDOfOpenBet", start, err)
+	return value, err
+}
+
+func (r *Repo) GetLastBetID() (int, error) {
+	start := time.Now()
+	value, err := r.Repo.GetLastBetID()
+	observeRepo("GetLastBetID", start, err)
+	return value, err
+}
+
+func (r *Repo) GetWinnerScore(betID int) (int, error) {
+	start := time.Now()
+	value, err := r.Repo.GetWinnerScore(betID)
+	observeRepo("GetWinnerScore", start, err)
+	return value, err
+}
+
+func (r *Repo) SetBetAsEnded(betID int, date time.Time) error {
+	start := time.Now()
+	err := r.Repo.SetBetAsEnded(betID, date)
+	observeRepo("SetBetAsEnded", start, err)
+	return err
+}
+
+func (r *Repo) SetBetDetail(betID int, details []repo.BetDetail) error {
+	start := time.Now()
+	err := r.Repo.SetBetDetail(betID, details)
+	observeRepo("SetBetDetail", start, err)
+	return err
+}
+
+func (r *Repo) SetBetWinner(betID int, winner int) error {
+	start := time.Now()
+	err := r.Repo.SetBetWinner(betID, winner)
+	observeRepo("SetBetWinner", start, err)
+	return err
+}
+
+func (r *Repo) ClearBetWinner(betID int) error {
+	start := time.Now()
+	err := r.Repo.ClearBetWinner(betID)
+	observeRepo("ClearBetWinner", start, err)
+	return err
+}
+
+func (r *Repo) ReopenBet(betID int) error {
+	start := time.Now()
+	err := r.Repo.ReopenBet(betID)
+	observeRepo("ReopenBet", start, err)
+	return err
+}
+
+func (r *Repo) SetBetVisibility(betID int, visibility string) error {
+	start := time.Now()
+	err := r.Repo.SetBetVisibility(betID, visibility)
+	observeRepo("SetBetVisibility", start, err)
+	return err
+}
+
+func (r *Repo) PurgeBet(betID int) error {
+	start := time.Now()
+	err := r.Repo.PurgeBet(betID)
+	observeRepo("PurgeBet", start, err)
+	return err
+}
+
+func (r *Repo) GetBetIDsForPeriod(period string) ([]int, error) {
+	start := time.Now()
+	value, err := r.Repo.GetBetIDsForPeriod(period)
+	observeRepo("GetBetIDsForPeriod", start, err)
+	return value, err
+}
+
+func (r *Repo) GetBetSummary(betID int) (*repo.BetSummary, error) {
+	start := time.Now()
+	value, err := r.Repo.GetBetSummary(betID)
+	observeRepo("GetBetSummary", start, err)
+	return value, err
+}
+
+func (r *Repo) GetBetSummaryRange(from int, to int) ([]repo.BetSummary, error) {
+	start := time.Now()
+	value, err := r.Repo.GetBetSummaryRange(from, to)
+	observeRepo("GetBetSummaryRange", start, err)
+	return value, err
+}
+
+func (r *Repo) GetBetSummaries(ids []int) ([]repo.BetSummary, error) {
+	start := time.Now()
+	value, err := r.Repo.GetBetSummaries(ids)
+	observeRepo("GetBetSummaries", start, err)
+	return value, err
+}
+
+func (r *Repo) GetBetWithDetails(betID int) (*repo.BetWithDetails, error) {
+	start := time.Now()
+	value, err := r.Repo.GetBetWithDetails(betID)
+	observeRepo("GetBetWithDetails", start, err)
+	return value, err
+}
+
+func (r *Repo) GetBetsWithDetails(ids []int) ([]repo.BetWithDetails, error) {
+	start := time.Now()
+	value, err := r.Repo.GetBetsWithDetails(ids)
+	observeRepo("GetBetsWithDetails", start, err)
+	return value, err
+}
+
+func (r *Repo) SetUserRole(user string, role string) error {
+	start := time.Now()
+	err := r.Repo.SetUserRole(user, role)
+	observeRepo("SetUserRole", start, err)
+	return err
+}
+
+func (r *Repo) RemoveUserRole(user string) error {
+	start := time.Now()
+	err := r.Repo.RemoveUserRole(user)
+	observeRepo("RemoveUserRole", start, err)
+	return err
+}
+
+func (r *Repo) GetUserRoles() (map[string]string, error) {
+	start := time.Now()
+	value, err := r.Repo.GetUserRoles()
+	observeRepo("GetUserRoles", start, err)
+	return value, err
+}
+
+func (r *Repo) AddAuditEntry(entry repo.AuditEntry) error {
+	start := time.Now()
+	err := r.Repo.AddAuditEntry(entry)
+	observeRepo("AddAuditEntry", start, err)
+	return err
+}
+
+func (r *Repo) GetAuditEntries(betID int) ([]repo.AuditEntry, error) {
+	start := time.Now()
+	value, err := r.Repo.GetAuditEntries(betID)
+	observeRepo("GetAuditEntries", start, err)
+	return value, err
+}
+
+func (r *Repo) Ping() error {
+	start := time.Now()
+	err := r.Repo.Ping()
+	observeRepo("Ping", start, err)
+	return err
+}
+
+func (r *Repo) SaveNotification(notification *repo.Notification) error {
+	start := time.Now()
+	err := r.Repo.SaveNotification(notification)
+	observeRepo("SaveNotification", start, err)
+	return err
+}
+
+func (r *Repo) RemoveNotification(id int) error {
+	start := time.Now()
+	err := r.Repo.RemoveNotification(id)
+	observeRepo("RemoveNotification", start, err)
+	return err
+}
+
+func (r *Repo) GetNotification(id int) (*repo.Notification, error) {
+	start := time.Now()
+	value, err := r.Repo.GetNotification(id)
+	observeRepo("GetNotification", start, err)
+	return value, err
+}
+
+func (r *Repo) GetDueNotifications(now time.Time, limit int) ([]repo.Notification, error) {
+	start := time.Now()
+	value, err := r.Repo.GetDueNotifications(now, limit)
+	observeRepo("GetDueNotifications", start, err)
+	return value, err
+}
+
+func (r *Repo) GetNextNotificationTime() (time.Time, error) {
+	start := time.Now()
+	value, err := r.Repo.GetNextNotificationTime()
+	observeRepo("GetNextNotificationTime", start, err)
+	return value, err
+}
+
+func (r *Repo) GetDeadNotifications() ([]repo.Notification, error) {
+	start := time.Now()
+	value, err := r.Repo.GetDeadNotifications()
+	observeRepo("GetDeadNotifications", start, err)
+	return value, err
+}
@@ -0,0 +1,178 @@
+// Package outbox queues Slack callbacks in the repo and delivers them with retries,
+// so that announcements are not lost while Slack is down or the bot restarts.
+package outbox
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/mtyurt/slackbet/metrics"
+	"github.com/mtyurt/slackbet/repo"
+)
+
+// Defaults of an Outbox made by New.
+const (
+	DefaultMaxAttempts  = 8
+	DefaultMinBackoff   = 5 * time.Second
+	DefaultMaxBackoff   = 10 * time.Minute
+	DefaultPollInterval = 30 * time.Second
+)
+
+// batchSize is the number of due notifications read from the repo at once.
+const batchSize = 20
+
+// Slack is the part of Slack that the outbox uses.
+type Slack interface {
+	GetChannelMembers(string) ([]string, error)
+	PostMessage(text string, channel string) error
+}
+
+// rateLimited is implemented by errors of Slack that ask to wait before the next request.
+type rateLimited interface {
+	RetryAfter() time.Duration
+}
+
+// Outbox is a slackbet.SlackService that saves callbacks in the repo, Run delivers them.
+// A failed delivery is retried after MinBackoff, doubling up to MaxBackoff, and the notification
+// is dead after MaxAttempts failures. Waiting for a rate limit is not counted as a failure.
+type Outbox struct {
+	Repo         repo.Repo
+	Slack        Slack
+	MaxAttempts  int
+	MinBackoff   time.Duration
+	MaxBackoff   time.Duration
+	PollInterval time.Duration
+	// Logger is slog.Default() if it is nil.
+	Logger *slog.Logger
+	wake   chan struct{}
+}
+
+// New returns an Outbox with default retry settings.
+func New(r repo.Repo, slack Slack) *Outbox {
+	return &Outbox{
+		Repo:         r,
+		Slack:        slack,
+		MaxAttempts:  DefaultMaxAttempts,
+		MinBackoff:   DefaultMinBackoff,
+		MaxBackoff:   DefaultMaxBackoff,
+		PollInterval: DefaultPollInterval,
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+func (outbox *Outbox) GetChannelMembers(channelID string) ([]string, error) {
+	return outbox.Slack.GetChannelMembers(channelID)
+}
+
+// SendCallback queues text to be posted to channel. If it cannot be queued, it is posted right away.
+func (outbox *Outbox) SendCallback(text string, channel string) {
+	now := time.Now()
+	notification := &repo.Notification{Channel: channel, Text: text, CreatedAt: now, NextAttempt: now}
+	err := outbox.Repo.SaveNotification(notification)
+	if err != nil {
+		outbox.logger().Error("notification cannot be queued, posting it directly", "channel", channel, "err", err)
+		err = outbox.Slack.PostMessage(text, channel)
+		metrics.SlackCallback(err)
+		if err != nil {
+			outbox.logger().Error("notification is lost", "channel", channel, "text", text, "err", err)
+		}
+		return
+	}
+	select {
+	case outbox.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run delivers notifications as they become due until ctx is done.
+func (outbox *Outbox) Run(ctx context.Context) {
+	for {
+		if err := outbox.Flush(ctx); err != nil && ctx.Err() == nil {
+			outbox.logger().Error("notifications cannot be delivered", "err", err)
+		}
+		wait := outbox.PollInterval
+		next, err := outbox.Repo.GetNextNotificationTime()
+		if err == nil && !next.IsZero() && time.Until(next) < wait {
+			wait = time.Until(next)
+		}
+		if wait < time.Second {
+			wait = time.Second
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-outbox.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// Flush delivers the notifications that are due now. It stops early if Slack is rate limiting or ctx is done,
+// notifications that are not delivered stay in the repo.
+func (outbox *Outbox) Flush(ctx context.Context) error {
+	for {
+		notifications, err := outbox.Repo.GetDueNotifications(time.Now(), batchSize)
+		if err != nil || len(notifications) == 0 {
+			return err
+		}
+		for _, notification := range notifications {
+			if err = ctx.Err(); err != nil {
+				return err
+			}
+			limited, err := outbox.deliver(notification)
+			if err != nil || limited {
+				return err
+			}
+		}
+	}
+}
+
+// deliver posts the notification and removes it, or schedules the next attempt if it fails.
+// Returns true if Slack is rate limiting.
+func (outbox *Outbox) deliver(notification repo.Notification) (bool, error) {
+	err := outbox.Slack.PostMessage(notification.Text, notification.Channel)
+	metrics.SlackCallback(err)
+	if err == nil {
+		return false, outbox.Repo.RemoveNotification(notification.ID)
+	}
+	notification.LastError = err.Error()
+	var limit rateLimited
+	if errors.As(err, &limit) {
+		notification.NextAttempt = time.Now().Add(limit.RetryAfter())
+		return true, outbox.Repo.SaveNotification(&notification)
+	}
+	notification.Attempts++
+	logger := outbox.logger().With("notification", notification.ID, "channel", notification.Channel, "attempts", notification.Attempts, "err", err)
+	if notification.Attempts >= outbox.MaxAttempts {
+		notification.Dead = true
+		logger.Error("notification is given up on")
+	} else {
+		notification.NextAttempt = time.Now().Add(outbox.backoff(notification.Attempts))
+		logger.Warn("notification cannot be delivered, it will be retried", "nextAttempt", notification.NextAttempt)
+	}
+	return false, outbox.Repo.SaveNotification(&notification)
+}
+
+// backoff returns the delay after the given number of failed attempts.
+func (outbox *Outbox) backoff(attempts int) time.Duration {
+	delay := outbox.MinBackoff
+	for i := 1; i < attempts && delay < outbox.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > outbox.MaxBackoff {
+		return outbox.MaxBackoff
+	}
+	return delay
+}
+
+func (outbox *Outbox) logger() *slog.Logger {
+	if outbox.Logger == nil {
+		return slog.Default()
+	}
+	return outbox.Logger
+}
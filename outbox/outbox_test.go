@@ -0,0 +1,115 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mediocregopher/radix.v2/redis"
+	"github.com/mtyurt/slackbet/repo"
+)
+
+type mockSlack struct {
+	errors []error
+	posted []string
+}
+
+func (slack *mockSlack) GetChannelMembers(channelID string) ([]string, error) {
+	return nil, nil
+}
+
+func (slack *mockSlack) PostMessage(text string, channel string) error {
+	if len(slack.errors) > 0 {
+		err := slack.errors[0]
+		slack.errors = slack.errors[1:]
+		if err != nil {
+			return err
+		}
+	}
+	slack.posted = append(slack.posted, text)
+	return nil
+}
+
+type rateLimitError struct{}
+
+func (rateLimitError) Error() string             { return "rate limited" }
+func (rateLimitError) RetryAfter() time.Duration { return time.Hour }
+
+func newOutbox(t *testing.T) (*Outbox, *mockSlack, *repo.RedisRepo) {
+	client, err := redis.Dial("tcp", "localhost:37564")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	client.Cmd("FLUSHALL")
+	r := &repo.RedisRepo{Url: "localhost:37564"}
+	slack := &mockSlack{}
+	outbox := New(r, slack)
+	outbox.MinBackoff = -time.Minute
+	outbox.MaxAttempts = 3
+	return outbox, slack, r
+}
+
+func TestDeliverWithRetries(t *testing.T) {
+	outbox, slack, r := newOutbox(t)
+	slack.errors = []error{errors.New("slack returned internal_error"), nil}
+	outbox.SendCallback("A new bet has started!", "#general")
+	outbox.SendCallback("omer has placed a bet. Have you?", "#general")
+
+	if err := outbox.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(slack.posted) != 2 || slack.posted[0] != "omer has placed a bet. Have you?" || slack.posted[1] != "A new bet has started!" {
+		t.Fatal("notifications should be delivered after the retry", slack.posted)
+	}
+	if due, _ := r.GetDueNotifications(time.Now(), 10); len(due) != 0 {
+		t.Fatal("delivered notifications should be removed", due)
+	}
+}
+
+func TestDeadNotification(t *testing.T) {
+	outbox, slack, r := newOutbox(t)
+	failure := errors.New("slack returned channel_not_found")
+	slack.errors = []error{failure, failure, failure}
+	outbox.SendCallback("A new bet has started!", "#general")
+
+	if err := outbox.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	dead, err := r.GetDeadNotifications()
+	if err != nil || len(dead) != 1 || dead[0].Attempts != 3 || dead[0].LastError != "slack returned channel_not_found" || len(slack.posted) != 0 {
+		t.Fatal("notification should be dead", err, dead, slack.posted)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	outbox, slack, r := newOutbox(t)
+	slack.errors = []error{rateLimitError{}}
+	outbox.SendCallback("A new bet has started!", "#general")
+	outbox.SendCallback("omer has placed a bet. Have you?", "#general")
+
+	if err := outbox.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(slack.posted) != 0 {
+		t.Fatal("nothing should be posted while rate limited", slack.posted)
+	}
+	next, err := r.GetNextNotificationTime()
+	if err != nil || next.After(time.Now().Add(time.Second)) {
+		t.Fatal("the second notification should still be due", next, err)
+	}
+	notification, _ := r.GetNotification(1)
+	if notification.Attempts != 0 || notification.NextAttempt.Before(time.Now().Add(59*time.Minute)) {
+		t.Fatal("rate limited notification should wait without counting an attempt", notification)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	outbox := New(nil, nil)
+	for attempts, expected := range map[int]time.Duration{1: 5 * time.Second, 2: 10 * time.Second, 4: 40 * time.Second, 20: 10 * time.Minute} {
+		if backoff := outbox.backoff(attempts); backoff != expected {
+			t.Fatal("backoff is wrong for", attempts, "attempts:", backoff)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package repo
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CachedRepo is a Repo that keeps closed bets in memory, other calls go to Repo.
+// Closed bets only change through the write methods of CachedRepo, which drop them from the cache,
+// so it must be the only writer of the underlying repo.
+type CachedRepo struct {
+	Repo
+	mu   sync.RWMutex
+	bets map[int]*cachedBet
+	// writes is increased on every write, reads that overlap a write are not cached.
+	writes int64
+	hits   int64
+	misses int64
+}
+
+// CacheStats are the hit and miss counts of a CachedRepo, counted per bet.
+type CacheStats struct {
+	Hits   int64
+	Misses int64
+}
+
+type cachedBet struct {
+	summary BetSummary
+	// details is nil until the details of the bet are read.
+	details []BetDetail
+}
+
+// Stats returns the hit and miss counts since the repo is created.
+func (repo *CachedRepo) Stats() CacheStats {
+	return CacheStats{Hits: atomic.LoadInt64(&repo.hits), Misses: atomic.LoadInt64(&repo.misses)}
+}
+
+func (repo *CachedRepo) lookup(betID int, needDetails bool) (*cachedBet, bool) {
+	repo.mu.RLock()
+	bet, ok := repo.bets[betID]
+	repo.mu.RUnlock()
+	if ok && (!needDetails || bet.details != nil) {
+		atomic.AddInt64(&repo.hits, 1)
+		return bet, true
+	}
+	atomic.AddInt64(&repo.misses, 1)
+	return nil, false
+}
+
+func (repo *CachedRepo) writeCount() int64 {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	return repo.writes
+}
+
+func (repo *CachedRepo) store(writes int64, summary BetSummary, details []BetDetail) {
+	if summary.Status != "closed" {
+		return
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if writes != repo.writes {
+		return
+	}
+	if repo.bets == nil {
+		repo.bets = make(map[int]*cachedBet)
+	}
+	if details == nil {
+		if old, ok := repo.bets[summary.ID]; ok {
+			details = old.details
+		}
+	}
+	repo.bets[summary.ID] = &cachedBet{summary: summary, details: details}
+}
+
+func (repo *CachedRepo) invalidate(betID int) {
+	repo.mu.Lock()
+	delete(repo.bets, betID)
+	repo.writes++
+	repo.mu.Unlock()
+}
+
+func copyDetails(details []BetDetail) []BetDetail {
+	c := make([]BetDetail, len(details))
+	copy(c, details)
+	return c
+}
+
+// BetIDExists returns true if a bet with given id exists
+// returns error for any connection error
+func (repo *CachedRepo) BetIDExists(betID int) (bool, error) {
+	if _, ok := repo.lookup(betID, false); ok {
+		return true, nil
+	}
+	return repo.Repo.BetIDExists(betID)
+}
+
+// GetBetSummary returns summary of bet with ID
+// return error for any connection error
+func (repo *CachedRepo) GetBetSummary(betID int) (*BetSummary, error) {
+	if bet, ok := repo.lookup(betID, false); ok {
+		summary := bet.summary
+		return &summary, nil
+	}
+	writes := repo.writeCount()
+	summary, err := repo.Repo.GetBetSummary(betID)
+	if err != nil {
+		return nil, err
+	}
+	repo.store(writes, *summary, nil)
+	return summary, nil
+}
+
+// GetBetSummaryRange returns summaries of existing bets with IDs between from and to, both inclusive,
+// in ascending order.
+// returns error in case of a connection error.
+func (repo *CachedRepo) GetBetSummaryRange(from int, to int) ([]BetSummary, error) {
+	if from < 1 {
+		from = 1
+	}
+	var ids []int
+	for id := from; id <= to; id++ {
+		ids = append(ids, id)
+	}
+	return repo.GetBetSummaries(ids)
+}
+
+// GetBetSummaries returns summaries of the existing bets among ids in the order of ids,
+// only the bets that are not cached are read from the underlying repo.
+// returns error in case of a connection error.
+func (repo *CachedRepo) GetBetSummaries(ids []int) ([]BetSummary, error) {
+	found := make(map[int]BetSummary)
+	var missing []int
+	for _, id := range ids {
+		if bet, ok := repo.lookup(id, false); ok {
+			found[id] = bet.summary
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		writes := repo.writeCount()
+		summaries, err := repo.Repo.GetBetSummaries(missing)
+		if err != nil {
+			return nil, err
+		}
+		for _, summary := range summaries {
+			repo.store(writes, summary, nil)
+			found[summary.ID] = summary
+		}
+	}
+	var summaries []BetSummary
+	for _, id := range ids {
+		if summary, ok := found[id]; ok {
+			summaries = append(summaries, summary)
+		}
+	}
+	return summaries, nil
+}
+
+// GetBetWithDetails returns the summary and details of the bet and whether it is the open bet.
+// Returns nil if the bet doesn't exist.
+// returns error in case of a connection error.
+func (repo *CachedRepo) GetBetWithDetails(betID int) (*BetWithDetails, error) {
+	if bet, ok := repo.lookup(betID, true); ok {
+		return &BetWithDetails{BetSummary: bet.summary, Details: copyDetails(bet.details)}, nil
+	}
+	writes := repo.writeCount()
+	bet, err := repo.Repo.GetBetWithDetails(betID)
+	if err != nil || bet == nil {
+		return bet, err
+	}
+	if !bet.IsOpen {
+		repo.store(writes, bet.BetSummary, copyDetails(bet.Details))
+	}
+	return bet, nil
+}
+
+// GetBetsWithDetails returns the summaries and details of the existing bets among ids in the order of ids,
+// only the bets that are not cached with their details are read from the underlying repo.
+// returns error in case of a connection error.
+func (repo *CachedRepo) GetBetsWithDetails(ids []int) ([]BetWithDetails, error) {
+	found := make(map[int]BetWithDetails)
+	var missing []int
+	for _, id := range ids {
+		if bet, ok := repo.lookup(id, true); ok {
+			found[id] = BetWithDetails{BetSummary: bet.summary, Details: copyDetails(bet.details)}
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		writes := repo.writeCount()
+		bets, err := repo.Repo.GetBetsWithDetails(missing)
+		if err != nil {
+			return nil, err
+		}
+		for _, bet := range bets {
+			if !bet.IsOpen {
+				repo.store(writes, bet.BetSummary, copyDetails(bet.Details))
+			}
+			found[bet.ID] = bet
+		}
+	}
+	var bets []BetWithDetails
+	for _, id := range ids {
+		if bet, ok := found[id]; ok {
+			bets = append(bets, bet)
+		}
+	}
+	return bets, nil
+}
+
+// GetBetDetails finds and returns details list of the bet.
+// returns error in case of a connection error.
+func (repo *CachedRepo) GetBetDetails(betID int) ([]BetDetail, error) {
+	if bet, ok := repo.lookup(betID, true); ok {
+		return copyDetails(bet.details), nil
+	}
+	return repo.Repo.GetBetDetails(betID)
+}
+
+// GetWinnerScore returns the winner score that belongs to the bet with betID.
+// Returns -1 if bet doesn't have a winnerScore.
+// returns error in case of a connection error.
+func (repo *CachedRepo) GetWinnerScore(betID int) (int, error) {
+	if bet, ok := repo.lookup(betID, false); ok {
+		return bet.summary.WinnerNumber, nil
+	}
+	return repo.Repo.GetWinnerScore(betID)
+}
+
+func (repo *CachedRepo) AddNewBet(betID int, startDate time.Time, sealed bool) error {
+	defer repo.invalidate(betID)
+	return repo.Repo.AddNewBet(betID, startDate, sealed)
+}
+
+func (repo *CachedRepo) SetBetAsEnded(betID int, date time.Time) error {
+	defer repo.invalidate(betID)
+	return repo.Repo.SetBetAsEnded(betID, date)
+}
+
+func (repo *CachedRepo) SetBetDetail(betID int, details []BetDetail) error {
+	defer repo.invalidate(betID)
+	return repo.Repo.SetBetDetail(betID, details)
+}
+
+func (repo *CachedRepo) SetBetWinner(betID int, winner int) error {
+	defer repo.invalidate(betID)
+	return repo.Repo.SetBetWinner(betID, winner)
+}
+
+func (repo *CachedRepo) ClearBetWinner(betID int) error {
+	defer repo.invalidate(betID)
+	return repo.Repo.ClearBetWinner(betID)
+}
+
+func (repo *CachedRepo) ReopenBet(betID int) error {
+	defer repo.invalidate(betID)
+	return repo.Repo.ReopenBet(betID)
+}
+
+func (repo *CachedRepo) SetBetVisibility(betID int, visibility string) error {
+	defer repo.invalidate(betID)
+	return repo.Repo.SetBetVisibility(betID, visibility)
+}
+
+func (repo *CachedRepo) PurgeBet(betID int) error {
+	defer repo.invalidate(betID)
+	return repo.Repo.PurgeBet(betID)
+}
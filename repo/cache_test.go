@@ -0,0 +1,56 @@
+package repo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCachedRepo(t *testing.T) {
+	r := &CachedRepo{Repo: &RedisRepo{Url: "localhost:37564"}}
+	client, err := r.Repo.(*RedisRepo).openRedisClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	client.Cmd("FLUSHALL")
+	start := time.Date(2016, 2, 1, 0, 0, 0, 0, time.UTC)
+	r.AddNewBet(1, start, false)
+	r.SetBetDetail(1, []BetDetail{{User: "omer", Number: 100}})
+	r.SetBetAsEnded(1, start.AddDate(0, 0, 1))
+	r.AddNewBet(2, start.AddDate(0, 1, 0), false)
+
+	r.GetBetWithDetails(1)
+	r.GetBetWithDetails(2)
+	if stats := r.Stats(); stats != (CacheStats{Hits: 0, Misses: 2}) {
+		t.Fatal("stats are wrong", stats)
+	}
+	bet, err := r.GetBetWithDetails(1)
+	if err != nil || bet.Details[0].User != "omer" {
+		t.Fatal("bet is wrong", err, bet)
+	}
+	bet.Details[0].Number = 0
+	summaries, _ := r.GetBetSummaryRange(1, 2)
+	details, _ := r.GetBetDetails(1)
+	if stats := r.Stats(); stats != (CacheStats{Hits: 3, Misses: 3}) || len(summaries) != 2 || details[0].Number != 100 {
+		t.Fatal("closed bet should be cached", stats, summaries, details)
+	}
+	bets, _ := r.GetBetsWithDetails([]int{1, 2})
+	if stats := r.Stats(); stats != (CacheStats{Hits: 4, Misses: 4}) || len(bets) != 2 || bets[0].Details[0].Number != 100 || !bets[1].IsOpen {
+		t.Fatal("only the open bet should be read", stats, bets)
+	}
+
+	client.Cmd("HSET", 1, "winner", 50)
+	if winner, _ := r.GetWinnerScore(1); winner != -1 {
+		t.Fatal("cached winner should be returned", winner)
+	}
+	r.SetBetWinner(1, 110)
+	if winner, _ := r.GetWinnerScore(1); winner != 110 {
+		t.Fatal("winner should be invalidated", winner)
+	}
+	r.GetBetSummary(1)
+	r.ReopenBet(1)
+	summary, _ := r.GetBetSummary(1)
+	if summary.Status != "open" {
+		t.Fatal("reopened bet should be invalidated", summary)
+	}
+}
@@ -0,0 +1,183 @@
+package repo
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/mediocregopher/radix.v2/redis"
+)
+
+// Notification is a message waiting to be posted to Slack. Pending notifications are delivered
+// at NextAttempt, dead notifications are not delivered again unless they are replayed.
+type Notification struct {
+	ID          int `json:"-"`
+	Channel     string
+	Text        string
+	CreatedAt   time.Time
+	Attempts    int
+	NextAttempt time.Time
+	LastError   string
+	Dead        bool
+}
+
+// Redis keys of notifications. Notifications are kept as JSON in a hash by ID, pending ones are
+// in a sorted set scored by the time of the next attempt, dead ones in a sorted set scored by ID.
+const (
+	notificationsKey       = "Notifications"
+	notificationIDKey      = "NotificationID"
+	pendingNotificationKey = "Outbox"
+	deadNotificationKey    = "DeadNotifications"
+)
+
+// SaveNotification saves the notification and puts it in the pending or dead queue,
+// a notification without an ID is assigned a new one.
+// returns error in case of a connection error.
+func (repo *RedisRepo) SaveNotification(notification *Notification) error {
+	client, err := repo.openRedisClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if notification.ID == 0 {
+		notification.ID, err = client.Cmd("INCR", notificationIDKey).Int()
+		if err != nil {
+			return err
+		}
+	}
+	marshalled, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	client.PipeAppend("HSET", notificationsKey, notification.ID, string(marshalled))
+	if notification.Dead {
+		client.PipeAppend("ZREM", pendingNotificationKey, notification.ID)
+		client.PipeAppend("ZADD", deadNotificationKey, notification.ID, notification.ID)
+	} else {
+		client.PipeAppend("ZREM", deadNotificationKey, notification.ID)
+		client.PipeAppend("ZADD", pendingNotificationKey, notification.NextAttempt.Unix(), notification.ID)
+	}
+	for i := 0; i < 3; i++ {
+		if err = client.PipeResp().Err; err != nil {
+			client.PipeClear()
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveNotification removes a delivered notification.
+// returns error in case of a connection error.
+func (repo *RedisRepo) RemoveNotification(id int) error {
+	client, err := repo.openRedisClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	client.PipeAppend("ZREM", pendingNotificationKey, id)
+	client.PipeAppend("ZREM", deadNotificationKey, id)
+	client.PipeAppend("HDEL", notificationsKey, id)
+	for i := 0; i < 3; i++ {
+		if err = client.PipeResp().Err; err != nil {
+			client.PipeClear()
+			return err
+		}
+	}
+	return nil
+}
+
+// GetNotification returns the notification with id, nil if it doesn't exist.
+// returns error in case of a connection error.
+func (repo *RedisRepo) GetNotification(id int) (*Notification, error) {
+	client, err := repo.openRedisClient()
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	notifications, err := repo.readNotifications(client, []string{strconv.Itoa(id)})
+	if err != nil || len(notifications) == 0 {
+		return nil, err
+	}
+	return &notifications[0], nil
+}
+
+// GetDueNotifications returns at most limit pending notifications whose next attempt is not after now,
+// the earliest first.
+// returns error in case of a connection error.
+func (repo *RedisRepo) GetDueNotifications(now time.Time, limit int) ([]Notification, error) {
+	client, err := repo.openRedisClient()
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	ids, err := client.Cmd("ZRANGEBYSCORE", pendingNotificationKey, "-inf", now.Unix(), "LIMIT", 0, limit).List()
+	if err != nil {
+		return nil, err
+	}
+	return repo.readNotifications(client, ids)
+}
+
+// GetNextNotificationTime returns the time of the earliest pending notification, zero if there is none.
+// returns error in case of a connection error.
+func (repo *RedisRepo) GetNextNotificationTime() (time.Time, error) {
+	client, err := repo.openRedisClient()
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer client.Close()
+	first, err := client.Cmd("ZRANGE", pendingNotificationKey, 0, 0, "WITHSCORES").List()
+	if err != nil || len(first) < 2 {
+		return time.Time{}, err
+	}
+	score, err := strconv.ParseFloat(first[1], 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(int64(score), 0), nil
+}
+
+// GetDeadNotifications returns notifications that are given up on, in the order of IDs.
+// returns error in case of a connection error.
+func (repo *RedisRepo) GetDeadNotifications() ([]Notification, error) {
+	client, err := repo.openRedisClient()
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	ids, err := client.Cmd("ZRANGE", deadNotificationKey, 0, -1).List()
+	if err != nil {
+		return nil, err
+	}
+	return repo.readNotifications(client, ids)
+}
+
+func (repo *RedisRepo) readNotifications(client *redis.Client, ids []string) ([]Notification, error) {
+	for _, id := range ids {
+		client.PipeAppend("HGET", notificationsKey, id)
+	}
+	var notifications []Notification
+	for _, id := range ids {
+		resp := client.PipeResp()
+		if resp.IsType(redis.Nil) {
+			continue
+		}
+		marshalled, err := resp.Str()
+		if err != nil {
+			client.PipeClear()
+			return nil, err
+		}
+		var notification Notification
+		err = json.Unmarshal([]byte(marshalled), &notification)
+		if err != nil {
+			client.PipeClear()
+			return nil, err
+		}
+		notification.ID, err = strconv.Atoi(id)
+		if err != nil {
+			client.PipeClear()
+			return nil, err
+		}
+		notifications = append(notifications, notification)
+	}
+	return notifications, nil
+}
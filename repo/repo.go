@@ -2,51 +2,135 @@ package repo
 
 import (
 	"encoding/json"
+	"log/slog"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/mediocregopher/radix.v2/redis"
 )
 
 type Repo interface {
-	AddNewBet(int, string) error
+	AddNewBet(int, time.Time, bool) error
 	BetIDExists(betID int) (bool, error)
 	GetBetDetails(int) ([]BetDetail, error)
 	GetIDOfOpenBet() (int, error)
 	GetLastBetID() (int, error)
 	GetWinnerScore(int) (int, error)
-	SetBetAsEnded(int, string) error
+	SetBetAsEnded(int, time.Time) error
 	SetBetDetail(int, []BetDetail) error
 	SetBetWinner(int, int) error
+	ClearBetWinner(int) error
+	ReopenBet(int) error
+	SetBetVisibility(int, string) error
+	PurgeBet(int) error
+	GetBetIDsForPeriod(string) ([]int, error)
 	GetBetSummary(betID int) (*BetSummary, error)
+	GetBetSummaryRange(from int, to int) ([]BetSummary, error)
+	GetBetSummaries(ids []int) ([]BetSummary, error)
+	GetBetWithDetails(betID int) (*BetWithDetails, error)
+	GetBetsWithDetails(ids []int) ([]BetWithDetails, error)
+	SetUserRole(string, string) error
+	RemoveUserRole(string) error
+	GetUserRoles() (map[string]string, error)
+	AddAuditEntry(AuditEntry) error
+	GetAuditEntries(betID int) ([]AuditEntry, error)
+	SaveNotification(*Notification) error
+	RemoveNotification(id int) error
+	GetNotification(id int) (*Notification, error)
+	GetDueNotifications(now time.Time, limit int) ([]Notification, error)
+	GetNextNotificationTime() (time.Time, error)
+	GetDeadNotifications() ([]Notification, error)
+	Ping() error
 }
+
+// RedisRepo keeps bets in Redis. Dates are stored in RFC 3339, dates saved in the
+// legacy "02-01-2006" format are read in Location, UTC if it is nil.
 type RedisRepo struct {
-	Url string
+	// Url is host:port or redis://host:port of the Redis server, localhost:37564 if it is empty.
+	Url      string
+	Location *time.Location
+	// Logger is slog.Default() if it is nil.
+	Logger *slog.Logger
 }
 type BetSummary struct {
 	ID           int
 	Status       string
-	StartDate    string
-	EndDate      string
+	StartDate    time.Time
+	EndDate      time.Time
 	WinnerNumber int
+	Visibility   string
+	// Sealed bets keep commitments of the guesses until their users reveal them.
+	Sealed bool
 }
 
+const legacyDateFormat = "02-01-2006"
+
+// PeriodFormat is the layout of periods in the period index, a period is a month of a year.
+const PeriodFormat = "2006-01"
+
+// Visibility values of a bet, bets are visible when it is empty.
+// Deleted bets are hidden everywhere, archived bets can only be seen by ID.
+const (
+	VisibilityDeleted  = "deleted"
+	VisibilityArchived = "archived"
+)
+
 func (b *BetSummary) String() string {
-	str := strconv.Itoa(b.ID) + "\tstart: " + b.StartDate
+	return b.Format(legacyDateFormat, time.UTC)
+}
+
+// Format returns the summary with dates in loc, formatted with layout.
+func (b *BetSummary) Format(layout string, loc *time.Location) string {
+	str := strconv.Itoa(b.ID) + "\tstart: " + b.StartDate.In(loc).Format(layout)
 	if b.Status == "open" {
 		str += "\t(still open)"
-	} else if b.EndDate != "" {
-		str += "\tend: " + b.EndDate
+	} else if !b.EndDate.IsZero() {
+		str += "\tend: " + b.EndDate.In(loc).Format(layout)
 	}
 	if b.WinnerNumber != -1 {
 		str += "\twinner score: " + strconv.Itoa(b.WinnerNumber)
 	}
+	if b.Visibility != "" {
+		str += "\t(" + b.Visibility + ")"
+	}
+	if b.Sealed {
+		str += "\t(sealed)"
+	}
 	return str
 }
 
+// BetWithDetails is everything GetBetInfo needs to show a bet, read in one round-trip.
+type BetWithDetails struct {
+	BetSummary
+	Details []BetDetail
+	IsOpen  bool
+}
+
+// BetDetail is the guess of a user. A guess in a sealed bet only has its commitment until it is revealed,
+// then Number and Salt are set.
 type BetDetail struct {
-	User      string
-	Number    int
-	ExtraInfo string
+	User       string
+	Number     int
+	ExtraInfo  string
+	Commitment string `json:",omitempty"`
+	Salt       string `json:",omitempty"`
+}
+
+// AuditEntry is a record of a state changing command. BetID is 0 for commands
+// that are not related to a bet, ID is the position of the entry in the audit log starting from 1.
+type AuditEntry struct {
+	ID       int `json:"-"`
+	Actor    string
+	Action   string
+	BetID    int
+	Target   string
+	OldValue string
+	NewValue string
+	// ExtraInfo is the extra info of the bet in OldValue, so that undo can restore it.
+	ExtraInfo string `json:",omitempty"`
+	Reverts   int
+	Timestamp time.Time
 }
 
 // SetBetWinner sets the winner field of the bet.
@@ -90,6 +174,152 @@ func (repo *RedisRepo) GetBetSummary(betID int) (*BetSummary, error) {
 	if err != nil {
 		return nil, err
 	}
+	return repo.parseSummary(betID, entry)
+}
+
+// GetBetSummaryRange returns summaries of existing bets with IDs between from and to, both inclusive,
+// in ascending order. Bets are read in a single pipeline.
+// returns error in case of a connection error.
+func (repo *RedisRepo) GetBetSummaryRange(from int, to int) ([]BetSummary, error) {
+	if from < 1 {
+		from = 1
+	}
+	var ids []int
+	for id := from; id <= to; id++ {
+		ids = append(ids, id)
+	}
+	return repo.GetBetSummaries(ids)
+}
+
+// GetBetSummaries returns summaries of the existing bets among ids in the order of ids,
+// missing bets are skipped. Bets are read in a single pipeline.
+// returns error in case of a connection error.
+func (repo *RedisRepo) GetBetSummaries(ids []int) ([]BetSummary, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	client, err := repo.openRedisClient()
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	for _, id := range ids {
+		client.PipeAppend("HGETALL", id)
+	}
+	var summaries []BetSummary
+	for _, id := range ids {
+		entry, err := client.PipeResp().Map()
+		if err != nil {
+			client.PipeClear()
+			return nil, err
+		}
+		if len(entry) == 0 {
+			continue
+		}
+		summary, err := repo.parseSummary(id, entry)
+		if err != nil {
+			client.PipeClear()
+			return nil, err
+		}
+		summaries = append(summaries, *summary)
+	}
+	return summaries, nil
+}
+
+// GetBetWithDetails returns the summary and details of the bet and whether it is the open bet,
+// in a single pipeline. Returns nil if the bet doesn't exist.
+// returns error in case of a connection error.
+func (repo *RedisRepo) GetBetWithDetails(betID int) (*BetWithDetails, error) {
+	client, err := repo.openRedisClient()
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	client.PipeAppend("HGETALL", betID)
+	client.PipeAppend("GET", "OpenBet")
+	entry, err := client.PipeResp().Map()
+	if err != nil {
+		client.PipeClear()
+		return nil, err
+	}
+	openBet := client.PipeResp()
+	if len(entry) == 0 {
+		return nil, nil
+	}
+	openBetID := -1
+	if !openBet.IsType(redis.Nil) {
+		openBetID, err = openBet.Int()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return repo.parseBetWithDetails(betID, entry, openBetID)
+}
+
+// GetBetsWithDetails returns the summaries and details of the existing bets among ids in the order of ids,
+// missing bets are skipped. Bets are read in a single pipeline.
+// returns error in case of a connection error.
+func (repo *RedisRepo) GetBetsWithDetails(ids []int) ([]BetWithDetails, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	client, err := repo.openRedisClient()
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	client.PipeAppend("GET", "OpenBet")
+	for _, id := range ids {
+		client.PipeAppend("HGETALL", id)
+	}
+	openBetID := -1
+	if openBet := client.PipeResp(); !openBet.IsType(redis.Nil) {
+		openBetID, err = openBet.Int()
+		if err != nil {
+			client.PipeClear()
+			return nil, err
+		}
+	}
+	var bets []BetWithDetails
+	for _, id := range ids {
+		entry, err := client.PipeResp().Map()
+		if err != nil {
+			client.PipeClear()
+			return nil, err
+		}
+		if len(entry) == 0 {
+			continue
+		}
+		bet, err := repo.parseBetWithDetails(id, entry, openBetID)
+		if err != nil {
+			client.PipeClear()
+			return nil, err
+		}
+		bets = append(bets, *bet)
+	}
+	return bets, nil
+}
+
+func (repo *RedisRepo) parseBetWithDetails(betID int, entry map[string]string, openBetID int) (*BetWithDetails, error) {
+	summary, err := repo.parseSummary(betID, entry)
+	if err != nil {
+		return nil, err
+	}
+	bet := &BetWithDetails{BetSummary: *summary, IsOpen: openBetID == betID}
+	if detailsStr, ok := entry["details"]; ok {
+		err = json.Unmarshal([]byte(detailsStr), &bet.Details)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return bet, nil
+}
+
+func (repo *RedisRepo) parseSummary(betID int, entry map[string]string) (*BetSummary, error) {
+	var err error
 	winnerNumber := -1
 	if winnerStr, ok := entry["winner"]; ok {
 		winnerNumber, err = strconv.Atoi(winnerStr)
@@ -97,11 +327,21 @@ func (repo *RedisRepo) GetBetSummary(betID int) (*BetSummary, error) {
 			return nil, err
 		}
 	}
+	startDate, err := repo.parseDate(entry["startDate"])
+	if err != nil {
+		return nil, err
+	}
+	endDate, err := repo.parseDate(entry["endDate"])
+	if err != nil {
+		return nil, err
+	}
 	return &BetSummary{Status: entry["status"],
-		StartDate:    entry["startDate"],
-		EndDate:      entry["endDate"],
+		StartDate:    startDate,
+		EndDate:      endDate,
 		ID:           betID,
-		WinnerNumber: winnerNumber}, nil
+		WinnerNumber: winnerNumber,
+		Visibility:   entry["visibility"],
+		Sealed:       entry["sealed"] == "true"}, nil
 }
 
 // GetBetDetails finds and returns details list of the bet.
@@ -180,14 +420,18 @@ func (repo *RedisRepo) GetWinnerScore(betID int) (int, error) {
 
 // SetBetAsEnded marks the bet as ended and sets the endDate with given date.
 // returns error in case of a connection error.
-func (repo *RedisRepo) SetBetAsEnded(betID int, date string) error {
+func (repo *RedisRepo) SetBetAsEnded(betID int, date time.Time) error {
 	client, err := repo.openRedisClient()
 	if err != nil {
 		return nil
 	}
 	defer client.Close()
 
-	err = client.Cmd("HMSET", betID, "status", "closed", "endDate", date).Err
+	err = client.Cmd("HMSET", betID, "status", "closed", "endDate", date.Format(time.RFC3339)).Err
+	if err != nil {
+		return err
+	}
+	err = repo.indexPeriod(client, betID, date)
 	if err != nil {
 		return err
 	}
@@ -198,16 +442,111 @@ func (repo *RedisRepo) SetBetAsEnded(betID int, date string) error {
 	return nil
 }
 
-// AddNewBet adds a new bet info with given id and startDate.
+// ReopenBet marks the ended bet as open again and removes its endDate.
+// returns error in case of a connection error.
+func (repo *RedisRepo) ReopenBet(betID int) error {
+	client, err := repo.openRedisClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	err = client.Cmd("HSET", betID, "status", "open").Err
+	if err != nil {
+		return err
+	}
+	err = client.Cmd("HDEL", betID, "endDate").Err
+	if err != nil {
+		return err
+	}
+	startDateStr, err := client.Cmd("HGET", betID, "startDate").Str()
+	if err != nil {
+		return err
+	}
+	startDate, err := repo.parseDate(startDateStr)
+	if err != nil {
+		return err
+	}
+	err = repo.indexPeriod(client, betID, startDate)
+	if err != nil {
+		return err
+	}
+	return client.Cmd("SET", "OpenBet", betID).Err
+}
+
+// ClearBetWinner removes the winner field of the bet.
+// returns error in case of a connection error.
+func (repo *RedisRepo) ClearBetWinner(betID int) error {
+	client, err := repo.openRedisClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	return client.Cmd("HDEL", betID, "winner").Err
+}
+
+// SetBetVisibility hides the bet as deleted or archived, an empty visibility makes it visible again.
+// returns error in case of a connection error.
+func (repo *RedisRepo) SetBetVisibility(betID int, visibility string) error {
+	client, err := repo.openRedisClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if visibility == "" {
+		return client.Cmd("HDEL", betID, "visibility").Err
+	}
+	return client.Cmd("HSET", betID, "visibility", visibility).Err
+}
+
+// PurgeBet removes the bet completely. If it is the open bet, there is no open bet anymore.
+// LastID is kept, so the ID of a purged bet is never reused by another bet.
+// returns error in case of a connection error.
+func (repo *RedisRepo) PurgeBet(betID int) error {
+	client, err := repo.openRedisClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	period := client.Cmd("HGET", betID, "period")
+	if !period.IsType(redis.Nil) {
+		periodStr, err := period.Str()
+		if err != nil {
+			return err
+		}
+		err = client.Cmd("ZREM", "Period:"+periodStr, betID).Err
+		if err != nil {
+			return err
+		}
+	}
+	err = client.Cmd("DEL", betID).Err
+	if err != nil {
+		return err
+	}
+	result := client.Cmd("GET", "OpenBet")
+	if openBetID, err := result.Int(); err == nil && openBetID == betID {
+		if err = client.Cmd("DEL", "OpenBet").Err; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddNewBet adds a new bet info with given id and startDate, marked as sealed if sealed is true.
 // returns error in case of a connection error.
-func (repo *RedisRepo) AddNewBet(betID int, startDate string) error {
+func (repo *RedisRepo) AddNewBet(betID int, startDate time.Time, sealed bool) error {
 	client, err := repo.openRedisClient()
 	if err != nil {
 		return nil
 	}
 	defer client.Close()
 
-	client.PipeAppend("HMSET", strconv.Itoa(betID), "startDate", startDate, "status", "open", "details", "[]")
+	fields := []interface{}{"startDate", startDate.Format(time.RFC3339), "status", "open", "details", "[]"}
+	if sealed {
+		fields = append(fields, "sealed", "true")
+	}
+	client.PipeAppend("HMSET", strconv.Itoa(betID), fields)
 	client.PipeAppend("SET", "LastID", betID)
 	client.PipeAppend("SET", "OpenBet", betID)
 	if err = client.PipeResp().Err; err != nil {
@@ -220,7 +559,7 @@ func (repo *RedisRepo) AddNewBet(betID int, startDate string) error {
 		return err
 	}
 	client.PipeClear()
-	return nil
+	return repo.indexPeriod(client, betID, startDate)
 }
 func (repo *RedisRepo) SetBetDetail(betID int, details []BetDetail) error {
 	client, err := repo.openRedisClient()
@@ -239,10 +578,272 @@ func (repo *RedisRepo) SetBetDetail(betID int, details []BetDetail) error {
 	}
 	return nil
 }
+
+// SetUserRole saves the role of the user. User names are stored in lower case.
+// returns error in case of a connection error.
+func (repo *RedisRepo) SetUserRole(user string, role string) error {
+	client, err := repo.openRedisClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	return client.Cmd("HSET", "Roles", strings.ToLower(user), role).Err
+}
+
+// RemoveUserRole removes the saved role of the user, they fall back to the default role.
+// returns error in case of a connection error.
+func (repo *RedisRepo) RemoveUserRole(user string) error {
+	client, err := repo.openRedisClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	return client.Cmd("HDEL", "Roles", strings.ToLower(user)).Err
+}
+
+// GetUserRoles returns saved roles keyed by user name, conf admins are not included.
+// returns error in case of a connection error.
+func (repo *RedisRepo) GetUserRoles() (map[string]string, error) {
+	client, err := repo.openRedisClient()
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	return client.Cmd("HGETALL", "Roles").Map()
+}
+
+// AddAuditEntry appends the entry to the audit log, entries are never modified.
+// returns error in case of a connection error.
+func (repo *RedisRepo) AddAuditEntry(entry AuditEntry) error {
+	client, err := repo.openRedisClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	marshalledEntry, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return client.Cmd("RPUSH", "AuditLog", string(marshalledEntry)).Err
+}
+
+// GetAuditEntries returns audit entries of the bet in insertion order, all entries if betID is -1.
+// returns error in case of a connection error.
+func (repo *RedisRepo) GetAuditEntries(betID int) ([]AuditEntry, error) {
+	client, err := repo.openRedisClient()
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	list, err := client.Cmd("LRANGE", "AuditLog", 0, -1).List()
+	if err != nil {
+		return nil, err
+	}
+	var entries []AuditEntry
+	for i, entryStr := range list {
+		var entry AuditEntry
+		err = json.Unmarshal([]byte(entryStr), &entry)
+		if err != nil {
+			return nil, err
+		}
+		entry.ID = i + 1
+		if betID == -1 || entry.BetID == betID {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// GetBetIDsForPeriod returns IDs of bets in the period, newest first. Period is formatted with PeriodFormat.
+// returns error in case of a connection error.
+func (repo *RedisRepo) GetBetIDsForPeriod(period string) ([]int, error) {
+	client, err := repo.openRedisClient()
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	list, err := client.Cmd("ZREVRANGE", "Period:"+period, 0, -1).List()
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int, len(list))
+	for i, idStr := range list {
+		ids[i], err = strconv.Atoi(idStr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+// indexPeriod moves the bet to the period of date in the period index. The period of a bet
+// is the month of its end date, or its start date while it is open, in repo.Location.
+func (repo *RedisRepo) indexPeriod(client *redis.Client, betID int, date time.Time) error {
+	period := date.In(repo.location()).Format(PeriodFormat)
+	oldPeriod := client.Cmd("HGET", betID, "period")
+	if !oldPeriod.IsType(redis.Nil) {
+		oldPeriodStr, err := oldPeriod.Str()
+		if err != nil {
+			return err
+		}
+		if oldPeriodStr != period {
+			err = client.Cmd("ZREM", "Period:"+oldPeriodStr, betID).Err
+			if err != nil {
+				return err
+			}
+		}
+	}
+	err := client.Cmd("ZADD", "Period:"+period, betID, betID).Err
+	if err != nil {
+		return err
+	}
+	return client.Cmd("HSET", betID, "period", period).Err
+}
+
+// IndexPeriods adds all bets to the period index, bets saved before the index existed are not in it.
+// Returns the number of indexed bets.
+// returns error in case of a connection error.
+func (repo *RedisRepo) IndexPeriods() (int, error) {
+	client, err := repo.openRedisClient()
+	if err != nil {
+		return 0, err
+	}
+	defer client.Close()
+
+	result := client.Cmd("GET", "LastID")
+	if result.IsType(redis.Nil) {
+		return 0, nil
+	}
+	lastID, err := result.Int()
+	if err != nil {
+		return 0, err
+	}
+	indexed := 0
+	for id := 1; id <= lastID; id++ {
+		entry, err := client.Cmd("HGETALL", id).Map()
+		if err != nil {
+			return indexed, err
+		}
+		dateStr := entry["endDate"]
+		if dateStr == "" {
+			dateStr = entry["startDate"]
+		}
+		if dateStr == "" {
+			continue
+		}
+		date, err := repo.parseDate(dateStr)
+		if err != nil {
+			return indexed, err
+		}
+		err = repo.indexPeriod(client, id, date)
+		if err != nil {
+			return indexed, err
+		}
+		indexed++
+	}
+	return indexed, nil
+}
+
+// MigrateDates rewrites dates of all bets saved in the legacy "02-01-2006" format in RFC 3339,
+// legacy dates are read in repo.Location. Returns the number of migrated bets.
+// returns error in case of a connection error.
+func (repo *RedisRepo) MigrateDates() (int, error) {
+	client, err := repo.openRedisClient()
+	if err != nil {
+		return 0, err
+	}
+	defer client.Close()
+
+	result := client.Cmd("GET", "LastID")
+	if result.IsType(redis.Nil) {
+		return 0, nil
+	}
+	lastID, err := result.Int()
+	if err != nil {
+		return 0, err
+	}
+	migrated := 0
+	for id := 1; id <= lastID; id++ {
+		entry, err := client.Cmd("HGETALL", id).Map()
+		if err != nil {
+			return migrated, err
+		}
+		var fields []interface{}
+		for _, field := range []string{"startDate", "endDate"} {
+			value, ok := entry[field]
+			if !ok {
+				continue
+			}
+			if _, err := time.Parse(time.RFC3339, value); err == nil {
+				continue
+			}
+			date, err := repo.parseDate(value)
+			if err != nil {
+				return migrated, err
+			}
+			fields = append(fields, field, date.Format(time.RFC3339))
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		err = client.Cmd("HMSET", append([]interface{}{id}, fields...)...).Err
+		if err != nil {
+			return migrated, err
+		}
+		migrated++
+	}
+	return migrated, nil
+}
+
+// parseDate parses dates saved in RFC 3339 or in the legacy format, an empty date is zero time.
+func (repo *RedisRepo) parseDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	date, err := time.Parse(time.RFC3339, value)
+	if err == nil {
+		return date, nil
+	}
+	return time.ParseInLocation(legacyDateFormat, value, repo.location())
+}
+
+func (repo *RedisRepo) location() *time.Location {
+	if repo.Location == nil {
+		return time.UTC
+	}
+	return repo.Location
+}
+
+// Ping checks that Redis is reachable.
+// returns error in case of a connection error.
+func (repo *RedisRepo) Ping() error {
+	client, err := repo.openRedisClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	return client.Cmd("PING").Err
+}
+
 func (repo *RedisRepo) openRedisClient() (*redis.Client, error) {
-	client, err := redis.Dial("tcp", "localhost:37564")
+	client, err := redis.Dial("tcp", repo.address())
 	if err != nil {
+		repo.logger().Error("redis cannot be reached", "err", err)
 		return nil, err
 	}
 	return client, nil
 }
+
+func (repo *RedisRepo) address() string {
+	if repo.Url == "" {
+		return "localhost:37564"
+	}
+	return strings.TrimPrefix(repo.Url, "redis://")
+}
+
+func (repo *RedisRepo) logger() *slog.Logger {
+	if repo.Logger == nil {
+		return slog.Default()
+	}
+	return repo.Logger
+}
@@ -1,6 +1,9 @@
 package repo
 
-import "testing"
+import (
+	"testing"
+	"time"
+)
 
 func TestGetBetSummary(t *testing.T) {
 	r := &RedisRepo{Url: "http://localhost:6379"}
@@ -10,3 +13,149 @@ func TestGetBetSummary(t *testing.T) {
 	}
 	//TODO first implement adding functionality, add bet here, then check with this function
 }
+
+func TestMigrateDates(t *testing.T) {
+	istanbul, err := time.LoadLocation("Europe/Istanbul")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &RedisRepo{Url: "localhost:37564", Location: istanbul}
+	client, err := r.openRedisClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	client.Cmd("FLUSHALL")
+	client.Cmd("HMSET", 1, "startDate", "01-02-2016", "endDate", "02-03-2016", "status", "closed")
+	client.Cmd("HMSET", 2, "startDate", "2016-03-02T10:00:00Z", "status", "open")
+	client.Cmd("SET", "LastID", 2)
+
+	migrated, err := r.MigrateDates()
+	if err != nil || migrated != 1 {
+		t.Fatal("migration failed", err, migrated)
+	}
+	betMap, _ := client.Cmd("HGETALL", 1).Map()
+	if betMap["startDate"] != "2016-02-01T00:00:00+02:00" || betMap["endDate"] != "2016-03-02T00:00:00+02:00" {
+		t.Fatal("dates are not migrated", betMap)
+	}
+	if startDate, _ := client.Cmd("HGET", 2, "startDate").Str(); startDate != "2016-03-02T10:00:00Z" {
+		t.Fatal("RFC 3339 date should not change", startDate)
+	}
+	summary, err := r.GetBetSummary(1)
+	if err != nil || !summary.StartDate.Equal(time.Date(2016, 2, 1, 0, 0, 0, 0, istanbul)) {
+		t.Fatal("start date is wrong", err, summary)
+	}
+	migrated, err = r.MigrateDates()
+	if err != nil || migrated != 0 {
+		t.Fatal("second migration should not change anything", err, migrated)
+	}
+}
+
+func TestGetBetWithDetails(t *testing.T) {
+	r := &RedisRepo{Url: "localhost:37564"}
+	client, err := r.openRedisClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	client.Cmd("FLUSHALL")
+	start := time.Date(2016, 2, 1, 0, 0, 0, 0, time.UTC)
+	r.AddNewBet(1, start, false)
+	r.SetBetDetail(1, []BetDetail{{User: "omer", Number: 100}})
+	r.SetBetAsEnded(1, start.AddDate(0, 0, 1))
+	r.SetBetWinner(1, 110)
+	r.AddNewBet(2, start.AddDate(0, 1, 0), true)
+	r.SetBetDetail(2, []BetDetail{{User: "omer", Commitment: "c0ffee"}})
+
+	bet, err := r.GetBetWithDetails(1)
+	if err != nil || bet.IsOpen || bet.Sealed || bet.WinnerNumber != 110 || len(bet.Details) != 1 || bet.Details[0].User != "omer" {
+		t.Fatal("bet is wrong", err, bet)
+	}
+	bet, err = r.GetBetWithDetails(2)
+	if err != nil || !bet.IsOpen || !bet.Sealed || len(bet.Details) != 1 || bet.Details[0] != (BetDetail{User: "omer", Commitment: "c0ffee"}) {
+		t.Fatal("bet is wrong", err, bet)
+	}
+	bet, err = r.GetBetWithDetails(3)
+	if err != nil || bet != nil {
+		t.Fatal("bet should not exist", err, bet)
+	}
+
+	summaries, err := r.GetBetSummaries([]int{2, 3, 1})
+	if err != nil || len(summaries) != 2 || summaries[0].ID != 2 || summaries[1].ID != 1 {
+		t.Fatal("summaries are wrong", err, summaries)
+	}
+	bets, err := r.GetBetsWithDetails([]int{2, 3, 1})
+	if err != nil || len(bets) != 2 || bets[0].ID != 2 || !bets[0].IsOpen || bets[1].ID != 1 || bets[1].IsOpen || bets[1].Details[0].Number != 100 {
+		t.Fatal("bets are wrong", err, bets)
+	}
+}
+
+func addBets(b *testing.B, r *RedisRepo, count int) []int {
+	client, err := r.openRedisClient()
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer client.Close()
+	client.Cmd("FLUSHALL")
+	var ids []int
+	start := time.Date(2016, 2, 1, 0, 0, 0, 0, time.UTC)
+	for id := 1; id <= count; id++ {
+		r.AddNewBet(id, start.AddDate(0, id, 0), false)
+		r.SetBetDetail(id, []BetDetail{{User: "omer", Number: id}, {User: "sezgin", Number: id + 1}})
+		r.SetBetAsEnded(id, start.AddDate(0, id, 1))
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// BenchmarkGetBetSummaryEach reads a 100-bet history with one round-trip per bet.
+func BenchmarkGetBetSummaryEach(b *testing.B) {
+	r := &RedisRepo{Url: "localhost:37564"}
+	ids := addBets(b, r, 100)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, id := range ids {
+			if _, err := r.GetBetSummary(id); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// BenchmarkGetBetSummaries reads a 100-bet history in a single pipeline.
+func BenchmarkGetBetSummaries(b *testing.B) {
+	r := &RedisRepo{Url: "localhost:37564"}
+	ids := addBets(b, r, 100)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := r.GetBetSummaries(ids); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkGetBetInfoSeparately reads a bet the way GetBetInfo used to, with four round-trips.
+func BenchmarkGetBetInfoSeparately(b *testing.B) {
+	r := &RedisRepo{Url: "localhost:37564"}
+	addBets(b, r, 100)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.BetIDExists(50)
+		r.GetBetSummary(50)
+		r.GetIDOfOpenBet()
+		if _, err := r.GetBetDetails(50); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetBetWithDetails(b *testing.B) {
+	r := &RedisRepo{Url: "localhost:37564"}
+	addBets(b, r, 100)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := r.GetBetWithDetails(50); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package slackbet
+
+import (
+	"time"
+
+	"github.com/mtyurt/slackbet/repo"
+)
+
+// Result is what a BetService method returns, package format renders results for Slack, the API and the CLI.
+type Result interface {
+	result()
+}
+
+// Confirmation is the result of a command that changes something. Action is the command, like "start" or
+// "savewinner", the other fields are set if the action has them.
+type Confirmation struct {
+	Action string
+	BetID  int
+	// User is the user whose bet or role is changed.
+	User string
+	// Value is the new value, like the winner score or the role.
+	Value string
+	// Salt is the salt of a sealed guess, only its user is given it.
+	Salt string
+	// Count is the number of notifications replayed.
+	Count int
+	// Reverted is the audit entry reverted by undo.
+	Reverted *repo.AuditEntry
+}
+
+// BetInfo is a bet as everyone can see it. Entries of an open bet are hidden, only their count is known
+// unless Conf.OpenBetReveal hides that too.
+type BetInfo struct {
+	ID        int
+	Status    string
+	StartDate time.Time
+	// EndDate is zero while the bet is open.
+	EndDate time.Time
+	// WinnerScore is -1 until the winner score is saved.
+	WinnerScore int
+	Visibility  string
+	// EntryCount is -1 if it is hidden.
+	EntryCount int
+	// Entries are sorted by number, nil while the bet is open.
+	Entries []Entry
+	// Sealed bets keep the guesses as commitments until their users reveal them.
+	Sealed bool
+	// Disqualified are the users whose sealed guesses are not revealed or don't match their commitment,
+	// they are not in Entries.
+	Disqualified []string
+}
+
+// Entry is the guess of a user.
+type Entry struct {
+	User      string
+	Number    int
+	ExtraInfo string
+	Winner    bool
+}
+
+// BetList is a page of bets, entries of the bets are not read.
+// More is true if there are older bets on the next page, bets after it are not read to count the pages.
+type BetList struct {
+	Bets []BetInfo
+	Page int
+	More bool
+}
+
+// WinnerReport lists who would win the last bet if the winner score was Reference.
+// BetID is -1 if there is no bet, Open is true if the last bet is still open and nobody is listed.
+type WinnerReport struct {
+	BetID        int
+	Open         bool
+	Participants int
+	Reference    int
+	Winners      []Entry
+}
+
+// AbsentList is the channel members who have not placed a bet in the open bet, BetID is -1 if there is no open bet.
+type AbsentList struct {
+	BetID int
+	Users []string
+}
+
+// RoleList is the users of every role above player, sorted by name.
+type RoleList struct {
+	Users map[Role][]string
+}
+
+// AuditLog is the latest entries of the audit log.
+type AuditLog struct {
+	Entries []repo.AuditEntry
+}
+
+// AuditExport is every entry of the audit log, it is rendered as CSV.
+type AuditExport struct {
+	Entries []repo.AuditEntry
+}
+
+// NotificationList is the callbacks that could not be delivered to Slack.
+type NotificationList struct {
+	Notifications []repo.Notification
+}
+
+// Leaderboard ranks users by the bets they won, only closed bets with a winner score are counted.
+type Leaderboard struct {
+	// Bets is the number of bets counted.
+	Bets    int
+	Players []Player
+}
+
+// Player is a user on the leaderboard, Bets is the number of counted bets they joined.
+type Player struct {
+	User string
+	Wins int
+	Bets int
+}
+
+// History is the visible closed bets that have guesses, in the order they were started.
+type History struct {
+	Bets []HistoryPoint
+}
+
+// HistoryPoint is a closed bet in History, WinnerScore is -1 if it is not saved.
+type HistoryPoint struct {
+	ID          int
+	EndDate     time.Time
+	WinnerScore int
+	Guesses     int
+	MedianGuess float64
+}
+
+// GuessStats summarizes the guesses of a closed bet. WinnerScore is -1 until it is saved,
+// MedianError is how far the median guess is from the winner score once it is saved.
+type GuessStats struct {
+	BetID       int
+	Count       int
+	Min         int
+	Max         int
+	Mean        float64
+	Median      float64
+	StdDev      float64
+	WinnerScore int
+	MedianError float64
+}
+
+func (*Confirmation) result()     {}
+func (*BetInfo) result()          {}
+func (*BetList) result()          {}
+func (*WinnerReport) result()     {}
+func (*AbsentList) result()       {}
+func (*RoleList) result()         {}
+func (*AuditLog) result()         {}
+func (*AuditExport) result()      {}
+func (*NotificationList) result() {}
+func (*Leaderboard) result()      {}
+func (*History) result()          {}
+func (*GuessStats) result()       {}
@@ -0,0 +1,178 @@
+// Package slack posts callbacks to Slack and keeps track of whether they are delivered.
+package slack
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mtyurt/slackbet/metrics"
+	"github.com/mtyurt/slackcommander"
+)
+
+const apiURL = "https://slack.com/api/"
+
+// Service is a slackbet.SlackService that posts messages itself to know their results,
+// channel members are read through slackcommander.
+type Service struct {
+	*slackcommander.SlackService
+	// APIURL is the base URL of the Slack Web API, https://slack.com/api/ if it is empty.
+	APIURL string
+	Client *http.Client
+	// Logger is slog.Default() if it is nil.
+	Logger *slog.Logger
+	mu     sync.RWMutex
+}
+
+// NewService returns a Service that posts with the bot token.
+func NewService(postToken string) *Service {
+	return &Service{SlackService: &slackcommander.SlackService{PostToken: postToken}, Client: http.DefaultClient}
+}
+
+// SetPostToken replaces the bot token, requests in progress keep using the old one.
+func (service *Service) SetPostToken(postToken string) {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+	service.SlackService = &slackcommander.SlackService{PostToken: postToken}
+}
+
+func (service *Service) commander() *slackcommander.SlackService {
+	service.mu.RLock()
+	defer service.mu.RUnlock()
+	return service.SlackService
+}
+
+// GetChannelMembers returns the members of the channel through slackcommander.
+func (service *Service) GetChannelMembers(channelID string) ([]string, error) {
+	return service.commander().GetChannelMembers(channelID)
+}
+
+// SendCallback posts text to channel, failures are counted and logged.
+func (service *Service) SendCallback(text string, channel string) {
+	err := service.PostMessage(text, channel)
+	metrics.SlackCallback(err)
+	if err != nil {
+		logger := service.Logger
+		if logger == nil {
+			logger = slog.Default()
+		}
+		logger.Error("callback cannot be sent", "channel", channel, "err", err)
+	}
+}
+
+// PostMessage posts text to channel as the bot user.
+// returns error if the request fails or Slack doesn't accept the message.
+func (service *Service) PostMessage(text string, channel string) error {
+	return service.call("chat.postMessage", url.Values{
+		"channel": {channel},
+		"text":    {text},
+		"as_user": {"true"},
+	})
+}
+
+// CheckToken checks that the bot token is accepted by Slack.
+func (service *Service) CheckToken() error {
+	return service.call("auth.test", url.Values{})
+}
+
+// UploadFile uploads content to the channel with channelID, see https://api.slack.com/messaging/files.
+// returns error if any step of the upload fails.
+func (service *Service) UploadFile(filename string, title string, content []byte, channelID string) error {
+	var upload struct {
+		UploadURL string `json:"upload_url"`
+		FileID    string `json:"file_id"`
+	}
+	err := service.callResult("files.getUploadURLExternal", url.Values{
+		"filename": {filename},
+		"length":   {strconv.Itoa(len(content))},
+	}, &upload)
+	if err != nil {
+		return err
+	}
+	resp, err := service.Client.Post(upload.UploadURL, "application/octet-stream", bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("slack returned " + resp.Status + " for the upload of " + filename)
+	}
+	files, err := json.Marshal([]map[string]string{{"id": upload.FileID, "title": title}})
+	if err != nil {
+		return err
+	}
+	return service.call("files.completeUploadExternal", url.Values{
+		"files":      {string(files)},
+		"channel_id": {channelID},
+	})
+}
+
+// call calls a Slack Web API method with the bot token.
+// returns error if the request fails or the response is not ok.
+func (service *Service) call(method string, values url.Values) error {
+	return service.callResult(method, values, nil)
+}
+
+// callResult calls a Slack Web API method like call and decodes the response into result if it is not nil.
+func (service *Service) callResult(method string, values url.Values, result interface{}) error {
+	base := service.APIURL
+	if base == "" {
+		base = apiURL
+	}
+	values.Set("token", service.commander().PostToken)
+	resp, err := service.Client.PostForm(base+method, values)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusTooManyRequests {
+		retryAfter, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+		if err != nil {
+			retryAfter = 1
+		}
+		return &RateLimitError{Wait: time.Duration(retryAfter) * time.Second}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("slack returned " + resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var status struct {
+		Ok    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	err = json.Unmarshal(body, &status)
+	if err != nil {
+		return err
+	}
+	if !status.Ok {
+		return errors.New("slack returned " + status.Error)
+	}
+	if result != nil {
+		return json.Unmarshal(body, result)
+	}
+	return nil
+}
+
+// RateLimitError is returned when Slack is rate limiting the bot, requests should wait for Wait.
+type RateLimitError struct {
+	Wait time.Duration
+}
+
+func (err *RateLimitError) Error() string {
+	return "slack is rate limiting, retry after " + err.Wait.String()
+}
+
+// RetryAfter returns how long to wait before the next request.
+func (err *RateLimitError) RetryAfter() time.Duration {
+	return err.Wait
+}
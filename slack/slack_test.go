@@ -0,0 +1,108 @@
+package slack
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPostMessage(t *testing.T) {
+	var channel, text string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("token") != "bot-token" {
+			fmt.Fprint(w, `{"ok":false,"error":"invalid_auth"}`)
+			return
+		}
+		channel, text = r.FormValue("channel"), r.FormValue("text")
+		fmt.Fprint(w, `{"ok":true}`)
+	}))
+	defer server.Close()
+
+	service := NewService("bot-token")
+	service.APIURL = server.URL + "/"
+	err := service.PostMessage("A new bet has started!", "#general")
+	if err != nil || channel != "#general" || text != "A new bet has started!" {
+		t.Fatal("message is not posted", err, channel, text)
+	}
+	service = NewService("wrong-token")
+	service.APIURL = server.URL + "/"
+	err = service.PostMessage("A new bet has started!", "#general")
+	if err == nil || err.Error() != "slack returned invalid_auth" {
+		t.Fatal("post should fail", err)
+	}
+}
+
+func TestCheckToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/auth.test" || r.FormValue("token") != "bot-token" {
+			fmt.Fprint(w, `{"ok":false,"error":"invalid_auth"}`)
+			return
+		}
+		fmt.Fprint(w, `{"ok":true}`)
+	}))
+	defer server.Close()
+
+	service := NewService("bot-token")
+	service.APIURL = server.URL + "/"
+	if err := service.CheckToken(); err != nil {
+		t.Fatal("token should be valid", err)
+	}
+	service.SetPostToken("wrong-token")
+	if err := service.CheckToken(); err == nil || err.Error() != "slack returned invalid_auth" {
+		t.Fatal("token should be invalid", err)
+	}
+}
+
+func TestUploadFile(t *testing.T) {
+	var uploaded, files, channelID string
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/files.getUploadURLExternal":
+			if r.FormValue("filename") != "bet-3.svg" || r.FormValue("length") != "5" {
+				fmt.Fprint(w, `{"ok":false,"error":"invalid_arguments"}`)
+				return
+			}
+			fmt.Fprintf(w, `{"ok":true,"upload_url":"%s/upload/F1","file_id":"F1"}`, server.URL)
+		case "/upload/F1":
+			body, _ := io.ReadAll(r.Body)
+			uploaded = string(body)
+		case "/files.completeUploadExternal":
+			files, channelID = r.FormValue("files"), r.FormValue("channel_id")
+			fmt.Fprint(w, `{"ok":true}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	service := NewService("bot-token")
+	service.APIURL = server.URL + "/"
+	err := service.UploadFile("bet-3.svg", "Guesses of bet #3", []byte("<svg>"), "C123")
+	if err != nil || uploaded != "<svg>" || files != `[{"id":"F1","title":"Guesses of bet #3"}]` || channelID != "C123" {
+		t.Fatal("file is not uploaded", err, uploaded, files, channelID)
+	}
+	err = service.UploadFile("other.svg", "", []byte("<svg>"), "C123")
+	if err == nil || err.Error() != "slack returned invalid_arguments" {
+		t.Fatal("upload should fail", err)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	service := NewService("bot-token")
+	service.APIURL = server.URL + "/"
+	err := service.PostMessage("A new bet has started!", "#general")
+	limit, ok := err.(*RateLimitError)
+	if !ok || limit.RetryAfter() != 30*time.Second {
+		t.Fatal("post should be rate limited", err)
+	}
+}
@@ -1,35 +1,235 @@
 package slackbet
 
-import "net/http"
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
 
+// TimeFormat is the default layout that dates are displayed in, see Conf.DateFormat.
 const TimeFormat = "02-01-2006"
 
 var Months = [...]string{"january", "february", "march", "april", "may", "june", "july", "august", "september", "october", "november", "december"}
 
+// ParsePeriod parses a month like "march", "mar 2025", "march 2025", "2025-03", "this month" or "last month".
+// A month without a year is its latest occurrence until now. Returns the first day of the month in the location of now.
+func ParsePeriod(text string, now time.Time) (time.Time, error) {
+	fields := strings.Fields(strings.ToLower(text))
+	year, month := now.Year(), now.Month()
+	invalid := errors.New(text + " is not a valid month.")
+	switch {
+	case len(fields) == 2 && fields[1] == "month" && (fields[0] == "this" || fields[0] == "current"):
+	case len(fields) == 2 && fields[1] == "month" && (fields[0] == "last" || fields[0] == "previous"):
+		month--
+	case len(fields) == 1 && strings.Contains(fields[0], "-"):
+		date, err := time.Parse("2006-01", fields[0])
+		if err != nil {
+			return time.Time{}, invalid
+		}
+		year, month = date.Year(), date.Month()
+	case len(fields) == 1 || len(fields) == 2:
+		month = parseMonth(fields[0])
+		if month == 0 {
+			return time.Time{}, invalid
+		}
+		if len(fields) == 2 {
+			var err error
+			year, err = strconv.Atoi(fields[1])
+			if err != nil {
+				return time.Time{}, invalid
+			}
+		} else if month > now.Month() {
+			year--
+		}
+	default:
+		return time.Time{}, invalid
+	}
+	return time.Date(year, month, 1, 0, 0, 0, 0, now.Location()), nil
+}
+
+// parseMonth returns the month with name or its first three letters, 0 if there is no such month.
+func parseMonth(name string) time.Month {
+	for i, m := range Months {
+		if m == name || (len(name) == 3 && strings.HasPrefix(m, name)) {
+			return time.Month(i + 1)
+		}
+	}
+	return 0
+}
+
+// ListQuery selects a page of bets, the newest bets are on the first page.
+type ListQuery struct {
+	Page  int
+	Count int
+	// Status is "open" or "closed", empty for both.
+	Status    string
+	HasWinner bool
+	// Year is the year that bets ended in, or started in if they are open. 0 for all years.
+	Year int
+}
+
+// ListQueryUsage describes the arguments of ParseListQuery.
+const ListQueryUsage = "[count] [page <n>] [open|closed] [has-winner] [year <yyyy>]"
+
+// ParseListQuery parses list arguments like "20", "page 2", "open", "closed", "has-winner", "year 2025" or "2025".
+func ParseListQuery(args []string) (ListQuery, error) {
+	invalid := errors.New("invalid list query, arguments are " + ListQueryUsage + ".")
+	query := ListQuery{}
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "open" || arg == "closed":
+			query.Status = arg
+		case arg == "has-winner":
+			query.HasWinner = true
+		case (arg == "page" || arg == "year") && i+1 < len(args) && IsAllInteger(args[i+1]):
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				return query, invalid
+			}
+			if arg == "page" {
+				query.Page = n
+			} else {
+				query.Year = n
+			}
+			i++
+		case IsAllInteger(arg) && len(arg) == 4:
+			query.Year, _ = strconv.Atoi(arg)
+		case IsAllInteger(arg):
+			query.Count, _ = strconv.Atoi(arg)
+		default:
+			return query, invalid
+		}
+	}
+	return query, nil
+}
+
+// IsAllInteger returns true if s has only the digits 0-9.
+func IsAllInteger(s string) bool {
+	for _, c := range []byte(s) {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+type Role int
+
+const (
+	RolePlayer Role = iota
+	RoleModerator
+	RoleAdmin
+	RoleOwner
+)
+
+var Roles = [...]string{"player", "moderator", "admin", "owner"}
+
+// DefaultPermissions maps commands to the minimum role that can run them.
+// Commands that are not listed can be run by everyone, Conf.Permissions overrides these.
+var DefaultPermissions = map[string]string{
+	"start":       "admin",
+	"end":         "admin",
+	"role":        "admin",
+	"savefor":     "moderator",
+	"listabsent":  "moderator",
+	"savewinner":  "owner",
+	"delete":      "owner",
+	"audit":       "moderator",
+	"reopen":      "admin",
+	"unsave":      "moderator",
+	"clearwinner": "owner",
+	"undo":        "admin",
+	"archive":     "admin",
+	"restore":     "owner",
+	"purge":       "owner",
+	"outbox":      "admin",
+	"seeguesses":  "admin",
+}
+
+func (r Role) String() string {
+	return Roles[r]
+}
+
+func ParseRole(name string) (Role, error) {
+	for i, r := range Roles {
+		if r == name {
+			return Role(i), nil
+		}
+	}
+	return RolePlayer, errors.New(name + " is not a valid role.")
+}
+
+// Reveal is how much of the guesses of a bet a viewer can see, each level includes the ones before it.
+type Reveal int
+
+const (
+	// RevealNothing hides whether anyone has placed a bet.
+	RevealNothing Reveal = iota
+	// RevealCount shows how many users have placed a bet.
+	RevealCount
+	// RevealParticipants shows who has placed a bet, but not their numbers.
+	RevealParticipants
+	// RevealAll shows every guess with its number.
+	RevealAll
+)
+
+var Reveals = [...]string{"nothing", "count", "participants", "all"}
+
+func (r Reveal) String() string {
+	return Reveals[r]
+}
+
+func ParseReveal(name string) (Reveal, error) {
+	for i, r := range Reveals {
+		if r == name {
+			return Reveal(i), nil
+		}
+	}
+	return RevealNothing, errors.New(name + " is not a valid reveal.")
+}
+
+// BetService runs bet commands, user arguments are the users running them.
 type BetService interface {
 	ParseRequestAndCheckToken(*http.Request) error
-	StartNewBet(string) (string, error)
-	EndBet(string) (string, error)
-	SaveBet(string, int, string) (string, error)
-	ListBets() (string, error)
-	GetBetInfo(int) (string, error)
-	GetBetInfoForMonth(int) (string, error)
-	CalculateWhoWins(int) (string, error)
-	SaveWinner(int, int) (string, error)
-	GetLastEndedBetInfo() (string, error)
-	ListAbsentUsers() (string, error)
-	IsAuthorizedUser(string) bool
+	StartNewBet(string) (*Confirmation, error)
+	EndBet(string) (*Confirmation, error)
+	SaveBet(string, int, string) (*Confirmation, error)
+	SaveBetFor(string, string, int) (*Confirmation, error)
+	ListBets(ListQuery) (*BetList, error)
+	GetBetInfo(int) (*BetInfo, error)
+	GetBetInfoForPeriod(string) (*BetInfo, error)
+	CalculateWhoWins(int) (*WinnerReport, error)
+	SaveWinner(string, int, int) (*Confirmation, error)
+	GetLastEndedBetInfo() (*BetInfo, error)
+	ListAbsentUsers(string) (*AbsentList, error)
+	HasPermission(string, string) bool
+	SetUserRole(string, string, string) (*Confirmation, error)
+	ListRoles() (*RoleList, error)
+	GetAuditLog(string, int) (*AuditLog, error)
+	ExportAuditLog(string, int) (*AuditExport, error)
+	ReopenBet(string, int) (*Confirmation, error)
+	UnsaveBet(string, string) (*Confirmation, error)
+	ClearWinner(string, int) (*Confirmation, error)
+	Undo(string) (*Confirmation, error)
+	DeleteBet(string, int) (*Confirmation, error)
+	ArchiveBet(string, int) (*Confirmation, error)
+	RestoreBet(string, int) (*Confirmation, error)
+	PurgeBet(string, int) (*Confirmation, error)
+	ListDeadNotifications(string) (*NotificationList, error)
+	ReplayNotification(string, int) (*Confirmation, error)
+	GetLeaderboard() (*Leaderboard, error)
+	GetHistory() (*History, error)
+	SaveSealedBet(string, string, string) (*Confirmation, error)
+	RevealBet(string, int, string) (*Confirmation, error)
+}
+
+// FileUploader uploads files to a Slack channel, channelID is the ID of the channel, not its name.
+type FileUploader interface {
+	UploadFile(filename string, title string, content []byte, channelID string) error
 }
 type SlackService interface {
 	GetChannelMembers(string) ([]string, error)
 	SendCallback(string, string)
 }
-type Conf struct {
-	Admins            []string `json:admins`
-	PostToken         string   `json:postToken`
-	Channel           string   `json:channel`
-	ChannelID         string   `json:channelId`
-	SlashCommandToken string   `json:slashCommandToken`
-	RedisUrl          string   `json:redisUrl`
-	Port              string   `json:port`
-}
@@ -0,0 +1,52 @@
+package slackbet
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePeriod(t *testing.T) {
+	now := time.Date(2025, time.May, 20, 10, 0, 0, 0, time.UTC)
+	cases := map[string]string{
+		"march":          "2025-03",
+		"may":            "2025-05",
+		"june":           "2024-06",
+		"Dec":            "2024-12",
+		"march 2023":     "2023-03",
+		"2023-11":        "2023-11",
+		"this month":     "2025-05",
+		"last month":     "2025-04",
+		"previous month": "2025-04",
+	}
+	for text, expected := range cases {
+		period, err := ParsePeriod(text, now)
+		if err != nil || period.Format("2006-01") != expected {
+			t.Error("period of", text, "is wrong, expected", expected, "but was", period, err)
+		}
+	}
+	january := time.Date(2025, time.January, 3, 0, 0, 0, 0, time.UTC)
+	if period, err := ParsePeriod("last month", january); err != nil || period.Format("2006-01") != "2024-12" {
+		t.Error("last month of january is wrong", period, err)
+	}
+	for _, text := range []string{"", "marchh", "march twenty", "2025-13", "next month", "ma"} {
+		if _, err := ParsePeriod(text, now); err == nil || err.Error() != text+" is not a valid month." {
+			t.Error("parsing", text, "should fail", err)
+		}
+	}
+}
+
+func TestParseListQuery(t *testing.T) {
+	query, err := ParseListQuery(strings.Fields("20 page 2 closed has-winner 2025"))
+	if err != nil || !reflect.DeepEqual(query, ListQuery{Count: 20, Page: 2, Status: "closed", HasWinner: true, Year: 2025}) {
+		t.Fatal("query is wrong", query, err)
+	}
+	query, err = ParseListQuery(strings.Fields("open year 2024"))
+	if err != nil || !reflect.DeepEqual(query, ListQuery{Status: "open", Year: 2024}) {
+		t.Fatal("query is wrong", query, err)
+	}
+	if _, err = ParseListQuery(strings.Fields("page two")); err == nil {
+		t.Fatal("query should fail")
+	}
+}